@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"io"
+
+	"github.com/azhai/xgen/dialect"
+	hcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// Validate 检查连接配置，返回带文件位置的诊断信息
+func (s *DbSettings) Validate() hcl.Diagnostics {
+	s.registerSecrets()
+	return dialect.ValidateConns(s.Repeats, s.Conns)
+}
+
+// WriteDiagnostics 输出诊断信息，附带配置文件中对应的代码片段
+func WriteDiagnostics(w io.Writer, filename string, diags hcl.Diagnostics) error {
+	parser := hclparse.NewParser()
+	_, _ = parser.ParseHCLFile(filename)
+	wr := hcl.NewDiagnosticTextWriter(w, parser.Files(), 78, false)
+	return wr.WriteDiagnostics(diags)
+}
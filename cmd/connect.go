@@ -1,18 +1,23 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/azhai/gozzo/config"
 	reverse "github.com/azhai/xgen"
 	"github.com/azhai/xgen/dialect"
+	hcl "github.com/hashicorp/hcl/v2"
 )
 
 var dbSettings = new(DbSettings)
 
 // DbSettings 数据库、缓存相关配置
 type DbSettings struct {
-	Reverse *reverse.ReverseConfig `hcl:"reverse,block" json:"reverse,omitempty"`
-	Repeats []dialect.RepeatConfig `hcl:"repeat,block" json:"repeat"`
-	Conns   []dialect.ConnConfig   `hcl:"conn,block" json:"conn"`
+	Reverse *reverse.ReverseConfig  `hcl:"reverse,block" json:"reverse,omitempty"`
+	Secrets []dialect.SecretCommand `hcl:"secret,block" json:"secret,omitempty"`
+	Repeats []dialect.RepeatConfig  `hcl:"repeat,block" json:"repeat"`
+	Conns   []dialect.ConnConfig    `hcl:"conn,block" json:"conn"`
 }
 
 // GetDbSettings 读取默认配置文件
@@ -22,10 +27,26 @@ func GetDbSettings(root *config.RootConfig) (*DbSettings, error) {
 	return settings, err
 }
 
+// registerSecrets 注册用于 cmd: 引用的密钥命令，检查和连接之前都要先注册
+func (s *DbSettings) registerSecrets() {
+	if len(s.Secrets) > 0 {
+		dialect.RegisterSecretCommands(s.Secrets...)
+	}
+}
+
 // GetConns 读取默认配置文件
 func (s *DbSettings) GetConns() []dialect.ConnConfig {
+	s.registerSecrets()
 	// 复制连接配置，用于同一个实例的不同数据库
 	if len(s.Repeats) > 0 {
+		err := dialect.DiscoverRepeats(s.Repeats, s.Conns, ListDatabases)
+		if diags, ok := err.(hcl.Diagnostics); ok { // 仍然使用已列出的数据库
+			for _, diag := range diags {
+				fmt.Fprintln(os.Stderr, "xx", diag.Error())
+			}
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, "xx", err)
+		}
 		adds := dialect.RepeatConns(s.Repeats, s.Conns)
 		if len(adds) > 0 {
 			s.Conns = append(s.Conns, adds...)
@@ -34,3 +55,18 @@ func (s *DbSettings) GetConns() []dialect.ConnConfig {
 	}
 	return s.Conns
 }
+
+// Redacted 复制一份隐藏了密码的配置，用于打印输出
+func (s *DbSettings) Redacted() *DbSettings {
+	copied := *s
+	copied.Conns = make([]dialect.ConnConfig, len(s.Conns))
+	for i, c := range s.Conns {
+		copied.Conns[i] = c.Redacted()
+	}
+	copied.Repeats = make([]dialect.RepeatConfig, len(s.Repeats))
+	for i, rep := range s.Repeats {
+		rep.Body = nil
+		copied.Repeats[i] = rep
+	}
+	return &copied
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/azhai/xgen/dialect"
+	"github.com/azhai/xgen/redisw"
+	"github.com/gomodule/redigo/redis"
+)
+
+// ListDatabases 列出服务器上的所有数据库，Redis只列出有数据的db
+func ListDatabases(cfg dialect.ConnConfig) ([]string, error) {
+	switch cfg.LoadDialect().(type) {
+	case *dialect.Mysql:
+		return querySqlNames(cfg, "SHOW DATABASES")
+	case *dialect.Postgres:
+		return querySqlNames(cfg, "SELECT datname FROM pg_database WHERE datistemplate = false")
+	case *dialect.Redis:
+		return listRedisDbs(cfg)
+	}
+	return nil, fmt.Errorf("cannot list databases of the connection type %s", cfg.Type)
+}
+
+func querySqlNames(cfg dialect.ConnConfig, sql string) (names []string, err error) {
+	engine := cfg.QuickConnect(false, false)
+	if engine == nil {
+		return nil, fmt.Errorf("cannot create the engine of %s", cfg.Key)
+	}
+	defer engine.Close()
+	sess := engine.NewSession()
+	defer sess.Close()
+	var rows [][]string
+	if rows, err = sess.QuerySliceString(sql); err != nil {
+		return
+	}
+	for _, row := range rows {
+		if len(row) > 0 {
+			names = append(names, row[0])
+		}
+	}
+	return
+}
+
+// listRedisDbs 根据 CONFIG GET databases 和 INFO keyspace 找出有数据的db
+func listRedisDbs(cfg dialect.ConnConfig) (names []string, err error) {
+	var conn redis.Conn
+	if conn, err = redisw.NewRedisConn(cfg); err != nil {
+		return
+	}
+	defer conn.Close()
+	total := -1 // 有些云服务禁用了CONFIG命令
+	if conf, err := redis.StringMap(conn.Do("CONFIG", "GET", "databases")); err == nil {
+		if total, err = strconv.Atoi(conf["databases"]); err != nil {
+			total = -1
+		}
+	}
+	var info string
+	if info, err = redis.String(conn.Do("INFO", "keyspace")); err != nil {
+		return
+	}
+	for _, line := range strings.Split(info, "\n") {
+		db, stats, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok || !strings.HasPrefix(db, "db") || !strings.Contains(stats, "keys=") {
+			continue
+		}
+		idx, err := strconv.Atoi(db[2:])
+		if err != nil || (total >= 0 && idx >= total) {
+			continue
+		}
+		names = append(names, db[2:])
+	}
+	return
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/azhai/xgen/dialect"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+// ImportDSN 将DSN连接串转为 conn 配置块，password不为空时替换DSN中的密码
+func ImportDSN(name, key, dsn, password string) ([]byte, error) {
+	cfg, err := dialect.ParseDSN(name, dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Key = key
+	if password != "" {
+		cfg.Password = password
+	}
+	return FormatConnBlock(cfg), nil
+}
+
+// FormatConnBlock 生成 conn 配置块，忽略空值
+func FormatConnBlock(cfg dialect.ConnConfig) []byte {
+	file := hclwrite.NewEmptyFile()
+	body := file.Body().AppendNewBlock("conn", []string{cfg.Type, cfg.Key}).Body()
+	if d := cfg.LoadDialect(); d != nil {
+		setStructAttrs(body, reflect.Indirect(reflect.ValueOf(d)))
+	}
+	setStringAttr(body, "username", cfg.Username)
+	setStringAttr(body, "password", cfg.Password)
+	if len(cfg.Options) > 0 {
+		var keys []string
+		for key := range cfg.Options {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		opts := make(map[string]cty.Value, len(keys))
+		for _, key := range keys {
+			opts[key] = cty.ListVal(stringVals(cfg.Options[key]))
+		}
+		body.SetAttributeValue("options", cty.ObjectVal(opts))
+	}
+	return file.Bytes()
+}
+
+// setStructAttrs 按照hcl标签写入非空字段
+func setStructAttrs(body *hclwrite.Body, rv reflect.Value) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		name, _, _ := strings.Cut(rt.Field(i).Tag.Get("hcl"), ",")
+		fv := rv.Field(i)
+		if name == "" || fv.IsZero() {
+			continue
+		}
+		ty, err := gocty.ImpliedType(fv.Interface())
+		if err != nil {
+			continue
+		}
+		if val, err := gocty.ToCtyValue(fv.Interface(), ty); err == nil {
+			body.SetAttributeValue(name, val)
+		}
+	}
+}
+
+func setStringAttr(body *hclwrite.Body, name, value string) {
+	if value != "" {
+		body.SetAttributeValue(name, cty.StringVal(value))
+	}
+}
+
+func stringVals(data []string) []cty.Value {
+	vals := make([]cty.Value, len(data))
+	for i, v := range data {
+		vals[i] = cty.StringVal(v)
+	}
+	return vals
+}
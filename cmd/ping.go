@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/azhai/xgen/dialect"
+	"github.com/azhai/xgen/redisw"
+	"github.com/azhai/xgen/utils"
+	"github.com/gomodule/redigo/redis"
+)
+
+// PingResult 单个连接的检查结果
+type PingResult struct {
+	Key     string
+	Type    string
+	Latency time.Duration
+	Version string
+	Err     error
+}
+
+// PingConns 并发检查所有连接，结果按连接名排序
+func PingConns(conns []dialect.ConnConfig, timeout time.Duration) []PingResult {
+	var wg sync.WaitGroup
+	results := make([]PingResult, len(conns))
+	for i, cfg := range conns {
+		wg.Add(1)
+		go func(i int, cfg dialect.ConnConfig) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			results[i] = PingConn(ctx, cfg)
+		}(i, cfg)
+	}
+	wg.Wait()
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Key < results[j].Key
+	})
+	return results
+}
+
+// OpenFlashDB 打开flashdb，返回的对象用完即关闭
+// xgen本身不依赖flashdb，需要检查时由引入了 github.com/arriqaaq/flashdb 的程序设置，例如
+//
+//	cmd.OpenFlashDB = func(d *dialect.FlashDB) (io.Closer, error) {
+//		return flashdb.New(&flashdb.Config{Path: d.Path, EvictionInterval: d.EvictionInterval})
+//	}
+var OpenFlashDB func(d *dialect.FlashDB) (io.Closer, error)
+
+// PingConn 检查单个连接，超时由ctx控制
+func PingConn(ctx context.Context, cfg dialect.ConnConfig) PingResult {
+	res := PingResult{Key: cfg.Key, Type: cfg.Type}
+	start := time.Now()
+	res.Version, res.Err = pingDialect(ctx, cfg)
+	res.Latency = time.Since(start)
+	if res.Err != nil && ctx.Err() != nil {
+		res.Err = ctx.Err()
+	}
+	return res
+}
+
+func pingDialect(ctx context.Context, cfg dialect.ConnConfig) (string, error) {
+	dia := cfg.LoadDialect()
+	if dia == nil {
+		return "", fmt.Errorf("unsupported connection type %s", cfg.Type)
+	}
+	if dia.IsXormDriver() {
+		return pingXorm(ctx, cfg)
+	}
+	switch d := dia.(type) {
+	case *dialect.Redis:
+		return pingRedis(ctx, cfg)
+	case *dialect.FlashDB:
+		return "", pingFlashDB(ctx, d)
+	}
+	return "", fmt.Errorf("cannot ping the connection type %s", cfg.Type)
+}
+
+func pingXorm(ctx context.Context, cfg dialect.ConnConfig) (string, error) {
+	engine := cfg.QuickConnect(false, false)
+	if engine == nil {
+		return "", fmt.Errorf("cannot create the engine of %s", cfg.Key)
+	}
+	defer engine.Close()
+	engine.SetDefaultContext(ctx) // DBVersion 使用默认的ctx
+	if err := engine.PingContext(ctx); err != nil {
+		return "", err
+	}
+	if _, err := engine.Context(ctx).QueryString("SELECT 1"); err != nil {
+		return "", err
+	}
+	ver, err := engine.DBVersion()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(ver.Number + " " + ver.Edition), nil
+}
+
+func pingRedis(ctx context.Context, cfg dialect.ConnConfig) (string, error) {
+	r := redisw.NewRedisPool(cfg, 1)
+	defer r.Close()
+	r.RetryTimes = 1 // 只检查一次，不重试
+	if _, err := r.ExecContext(ctx, "PING"); err != nil {
+		return "", err
+	}
+	info, err := redis.String(r.ExecContext(ctx, "INFO", "server"))
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(info, "\n") {
+		if ver, ok := strings.CutPrefix(line, "redis_version:"); ok {
+			return strings.TrimSpace(ver), nil
+		}
+	}
+	return "", nil
+}
+
+// pingFlashDB 打开再关闭flashdb，它是嵌入式的，没有服务端
+func pingFlashDB(ctx context.Context, d *dialect.FlashDB) error {
+	if d.Path == "" {
+		return fmt.Errorf("the path of flashdb is empty")
+	}
+	if OpenFlashDB == nil {
+		return fmt.Errorf("the flashdb driver is not linked, set cmd.OpenFlashDB first")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.Path, utils.DefaultDirMode); err != nil {
+		return err
+	}
+	db, err := OpenFlashDB(d)
+	if err != nil {
+		return err
+	}
+	return db.Close()
+}
+
+// WritePingReport 输出检查结果表格，返回失败的数量
+func WritePingReport(w io.Writer, results []PingResult) (fails int) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "KEY\tTYPE\tLATENCY\tVERSION\tERROR")
+	for _, res := range results {
+		errMsg := "-"
+		if res.Err != nil {
+			fails++
+			errMsg = res.Err.Error()
+		}
+		latency := res.Latency.Round(time.Microsecond)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", res.Key, res.Type, latency, res.Version, errMsg)
+	}
+	_ = tw.Flush()
+	return
+}
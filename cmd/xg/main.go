@@ -2,7 +2,9 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"sync"
+	"time"
 
 	"github.com/alexflint/go-arg"
 	"github.com/azhai/gozzo/config"
@@ -24,6 +26,9 @@ var args struct {
 	Pretty     *prettyCmd   `arg:"subcommand:pretty" help:"美化代码"`
 	Mixin      *mixinCmd    `arg:"subcommand:mixin" help:"嵌入Mixins"`
 	Skeleton   *skeletonCmd `arg:"subcommand:skeleton" help:"生成新项目"`
+	Check      *checkCmd    `arg:"subcommand:check" help:"检查配置"`
+	ImportDSN  *importCmd   `arg:"subcommand:import-dsn" help:"将DSN转为conn配置"`
+	Ping       *pingCmd     `arg:"subcommand:ping" help:"检查所有连接"`
 	Config     string       `arg:"-c,--config" default:"settings.hcl" help:"配置文件路径"`
 	Verbose    bool         `arg:"-v,--verbose" help:"输出详细信息"`
 	IsInteract bool         `arg:"-i,--interact" help:"交互模式"`
@@ -36,6 +41,20 @@ type prettyCmd struct {
 type mixinCmd struct {
 }
 
+type checkCmd struct {
+}
+
+type pingCmd struct {
+	Timeout int `arg:"-t,--timeout" default:"5" help:"单个连接最大等待时长，单位：秒"`
+}
+
+type importCmd struct {
+	Type     string `arg:"positional,required" help:"数据库类型"`
+	Key      string `arg:"positional,required" help:"数据库连接名"`
+	DSN      string `arg:"positional,required" help:"DSN连接串"`
+	Password string `arg:"-p,--password" help:"替换密码，例如 env:PG_PASS"`
+}
+
 type skeletonCmd struct {
 	BinName string `arg:"-b,--bin" default:"serv" help:"二进制文件名"`
 	IsForce bool   `arg:"-f,--force" help:"覆盖文件"`
@@ -59,12 +78,40 @@ func main() {
 		return
 	}
 
+	if imp := args.ImportDSN; imp != nil { // 仅转换DSN
+		block, err := cmd.ImportDSN(imp.Type, imp.Key, imp.DSN, imp.Password)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Print(string(block))
+		return
+	}
+
 	settings := new(cmd.DbSettings)
 	_, err := config.ReadConfigFile(args.Config, settings)
 	if err != nil {
 		panic(err)
 	}
 	// models.PrepareConns(root)
+	if args.Verbose {
+		_, _ = pp.Println(settings.Redacted())
+	}
+	if diags := settings.Validate(); diags.HasErrors() || args.Check != nil {
+		_ = cmd.WriteDiagnostics(os.Stderr, args.Config, diags)
+		if diags.HasErrors() {
+			os.Exit(1)
+		}
+		fmt.Printf("检查完成，共 %d 个连接。\n", len(settings.Conns))
+		return // 仅检查配置
+	}
+	if args.Ping != nil { // 仅检查连接
+		timeout := time.Duration(args.Ping.Timeout) * time.Second
+		results := cmd.PingConns(settings.GetConns(), timeout)
+		if cmd.WritePingReport(os.Stdout, results) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
 	if args.IsInteract { // 采用交互模式，确定或修改部分配置
 		if err = questions(settings); err != nil {
 			fmt.Println("跳过，什么也没有做！")
@@ -177,7 +224,7 @@ func questions(settings *cmd.DbSettings) (err error) {
 	}
 	settings.Reverse.NameSpace = val
 
-	_, _ = pp.Println(settings.Reverse)
+	_, _ = pp.Println(settings.Redacted())
 	prompt = promptui.Prompt{
 		Label:     "使用以上配置，是否继续",
 		IsConfirm: true,
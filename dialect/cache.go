@@ -0,0 +1,87 @@
+package dialect
+
+import (
+	"fmt"
+
+	hcl "github.com/hashicorp/hcl/v2"
+)
+
+const (
+	CACHE_DEFAULT_TIMEOUT  = 3600 // 缓存时长，单位：秒
+	CACHE_DEFAULT_MAX_SIZE = 1000 // 本地LRU索引最多记录的数量
+)
+
+// CacheConfig 使用Redis作为Xorm的二级缓存，tables为空时缓存所有表
+type CacheConfig struct {
+	Conn     string   `hcl:"conn" json:"conn"`                            // Redis连接名
+	Tables   []string `hcl:"tables,optional" json:"tables,omitempty"`     // 缓存的表名
+	Timeout  int      `hcl:"timeout,optional" json:"timeout,omitempty"`   // 单位：秒
+	MaxSize  int      `hcl:"max_size,optional" json:"max_size,omitempty"` // LRU索引大小
+	Codec    string   `hcl:"codec,optional" json:"codec,omitempty"`       // gob/json/msgpack
+	Compress string   `hcl:"compress,optional" json:"compress,omitempty"` // gzip/zstd
+}
+
+// GetTimeout 缓存时长，未设置时使用默认值
+func (c CacheConfig) GetTimeout() int {
+	if c.Timeout <= 0 {
+		return CACHE_DEFAULT_TIMEOUT
+	}
+	return c.Timeout
+}
+
+// GetMaxSize LRU索引大小，未设置时使用默认值
+func (c CacheConfig) GetMaxSize() int {
+	if c.MaxSize <= 0 {
+		return CACHE_DEFAULT_MAX_SIZE
+	}
+	return c.MaxSize
+}
+
+// HasTable 是否缓存这张表
+func (c CacheConfig) HasTable(table string) bool {
+	if len(c.Tables) == 0 {
+		return true
+	}
+	for _, name := range c.Tables {
+		if name == table {
+			return true
+		}
+	}
+	return false
+}
+
+// validateCache 检查缓存引用的连接是否为Redis
+func (c ConnConfig) validateCache(keys map[string]*ConnConfig) (diags hcl.Diagnostics) {
+	if c.Cache == nil {
+		return
+	}
+	if conn, ok := keys[c.Cache.Conn]; !ok || conn.Type != "redis" {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Cache of missing conn",
+			Detail:   fmt.Sprintf("The conn %q caches into %q which is not a redis conn.", c.Key, c.Cache.Conn),
+			Subject:  c.DefRange().Ptr(),
+		})
+	}
+	switch c.Cache.Codec {
+	case "", "gob", "json", "msgpack":
+	default:
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid cache codec",
+			Detail:   fmt.Sprintf("The conn %q has codec %q, which must be gob, json or msgpack.", c.Key, c.Cache.Codec),
+			Subject:  c.DefRange().Ptr(),
+		})
+	}
+	switch c.Cache.Compress {
+	case "", "none", "gzip", "zstd":
+	default:
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid cache compress",
+			Detail:   fmt.Sprintf("The conn %q has compress %q, which must be gzip or zstd.", c.Key, c.Cache.Compress),
+			Subject:  c.DefRange().Ptr(),
+		})
+	}
+	return
+}
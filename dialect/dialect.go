@@ -1,14 +1,15 @@
 package dialect
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
+	"reflect"
 	"strings"
 
 	"github.com/azhai/gozzo/logging/adapters/xormlog"
 	"github.com/azhai/xgen/utils"
 	hcl "github.com/hashicorp/hcl/v2"
-	"github.com/hashicorp/hcl/v2/gohcl"
 	"xorm.io/xorm"
 )
 
@@ -52,10 +53,20 @@ func CreateDialectByName(name string) Dialect {
 
 // RepeatConfig 复制连接参数，只有数据库不同，目前只支持Mysql/Postgres/Redis
 type RepeatConfig struct {
-	Type     string   `hcl:"type,label" json:"type"`
-	Key      string   `hcl:"key" json:"key"`
-	DbPrefix string   `hcl:"db_prefix,optional" json:"db_prefix,omitempty"`
-	DbNames  []string `hcl:"db_names,optional" json:"db_names,omitempty"`
+	Type      string   `hcl:"type,label" json:"type"`
+	Key       string   `hcl:"key" json:"key"`
+	DbPrefix  string   `hcl:"db_prefix,optional" json:"db_prefix,omitempty"`
+	DbNames   []string `hcl:"db_names,optional" json:"db_names,omitempty"`
+	DbPattern string   `hcl:"db_pattern,optional" json:"db_pattern,omitempty"` // 通配符或者 /正则式/
+	Body      hcl.Body `hcl:",body" json:"-"`
+}
+
+// DefRange 复制配置在文件中的位置
+func (r RepeatConfig) DefRange() hcl.Range {
+	if r.Body == nil {
+		return hcl.Range{}
+	}
+	return r.Body.MissingItemRange()
 }
 
 // RepeatConns 复制配置
@@ -93,29 +104,49 @@ type ConnConfig struct {
 	Password string     `hcl:"password,optional" json:"password,omitempty"`
 	DSN      string     `hcl:"dsn,optional" json:"dsn,omitempty"`
 	Options  url.Values `hcl:"options,optional" json:"options,omitempty"`
-	Remain   hcl.Body   `hcl:",remain"`
-	Dialect  Dialect
+
+	MaxOpen         int `hcl:"max_open,optional" json:"max_open,omitempty"`                     // 最大连接数
+	MaxIdle         int `hcl:"max_idle,optional" json:"max_idle,omitempty"`                     // 最大空闲连接数
+	ConnMaxLifetime int `hcl:"conn_max_lifetime,optional" json:"conn_max_lifetime,omitempty"`   // 单位：秒
+	ConnMaxIdleTime int `hcl:"conn_max_idle_time,optional" json:"conn_max_idle_time,omitempty"` // 单位：秒
+
+	ReplicaDSNs   []string        `hcl:"replicas,optional" json:"replicas,omitempty"`             // 只读副本的DSN
+	Replicas      []ReplicaConfig `hcl:"replica,block" json:"replica,omitempty"`                  // 只读副本
+	ReplicaPolicy string          `hcl:"replica_policy,optional" json:"replica_policy,omitempty"` // 负载均衡策略
+
+	Cache *CacheConfig `hcl:"cache,block" json:"cache,omitempty"` // Redis二级缓存
+
+	Remain  hcl.Body `hcl:",remain"`
+	Dialect Dialect
 }
 
-// LoadDialect 加载数据库驱动配置
+// LoadDialect 加载数据库驱动配置，忽略诊断信息，需要时先调用 Validate 检查
 func (c *ConnConfig) LoadDialect() Dialect {
-	if c.Type == "" || c.Dialect != nil {
-		return c.Dialect
-	}
-	c.Dialect = CreateDialectByName(c.Type)
-	if c.Dialect != nil && c.Remain != nil {
-		gohcl.DecodeBody(c.Remain, nil, c.Dialect)
-	}
-	return c.Dialect
+	dia, _ := c.DecodeDialect()
+	return dia
 }
 
 // CopyIt 复制一份完整配置，但修改它的连接名
 func (c ConnConfig) CopyIt(key string) ConnConfig {
+	if c.Dialect != nil { // 已加载的驱动配置是指针，需要另外复制
+		c.Dialect = CloneDialect(c.Dialect)
+	}
 	c.LoadDialect()
 	c.Key = key
 	return c
 }
 
+// CloneDialect 复制一份驱动配置
+func CloneDialect(d Dialect) Dialect {
+	v := reflect.ValueOf(d)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return d
+	}
+	dup := reflect.New(v.Elem().Type())
+	dup.Elem().Set(v.Elem())
+	return dup.Interface().(Dialect)
+}
+
 // Name 数据库驱动名
 func (c ConnConfig) Name() string {
 	if d := c.LoadDialect(); d != nil {
@@ -124,15 +155,37 @@ func (c ConnConfig) Name() string {
 	return c.Type
 }
 
-// GetDSN 获取DSN连接串，可选是否带账号密码
+// GetPassword 获取密码，支持 env: file: cmd: 等引用
+func (c ConnConfig) GetPassword() (string, error) {
+	return ResolveSecret(c.Password)
+}
+
+// GetDSN 获取DSN连接串，可选是否带账号密码，引用无法解析时为空，连接时应使用 ResolveDSN
 func (c ConnConfig) GetDSN(full bool) string {
+	dsn, _ := c.ResolveDSN(full)
+	return dsn
+}
+
+// ResolveDSN 获取DSN连接串，密码或DSN的引用无法解析时返回错误
+func (c ConnConfig) ResolveDSN(full bool) (string, error) {
 	var dsn string
-	if d := c.LoadDialect(); d != nil {
-		if c.DSN == "" {
-			c.DSN = d.BuildDSN()
+	// 普通的DSN已解析到驱动配置中，剩下的是引用
+	d := c.LoadDialect()
+	if _, isSqlite := d.(*Sqlite); c.DSN != "" && !isSqlite { // sqlite的DSN本身以file:开头
+		secret, err := ResolveSecret(c.DSN)
+		if err != nil {
+			return "", fmt.Errorf("the dsn of conn %s: %w", c.Key, err)
 		}
+		dsn = secret
+	} else if d != nil {
 		if full {
-			dsn = d.BuildFullDSN(c.Username, c.Password)
+			password, err := c.GetPassword()
+			if err != nil {
+				return "", fmt.Errorf("the password of conn %s: %w", c.Key, err)
+			}
+			dsn = d.BuildFullDSN(c.Username, password)
+		} else {
+			dsn = d.BuildDSN()
 		}
 	}
 	if dsn == "" {
@@ -141,15 +194,52 @@ func (c ConnConfig) GetDSN(full bool) string {
 	if args := c.Options.Encode(); args != "" {
 		dsn += args
 	}
-	return strings.TrimRight(dsn, " ?&")
+	return strings.TrimRight(dsn, " ?&"), nil
+}
+
+// Redacted 复制一份隐藏了密码的配置，用于打印输出
+func (c ConnConfig) Redacted() ConnConfig {
+	c.LoadDialect()
+	c.Password = RedactSecret(c.Password)
+	c.DSN = RedactDSN(c.DSN)
+	c.Remain = nil // 已解析到Dialect中
+	if d, ok := c.Dialect.(*Redis); ok && d.SentinelPassword != "" {
+		dup := *d // 不要修改原来的Dialect
+		dup.SentinelPassword = RedactSecret(d.SentinelPassword)
+		c.Dialect = &dup
+	}
+	dsns := make([]string, len(c.ReplicaDSNs))
+	for i, dsn := range c.ReplicaDSNs {
+		dsns[i] = RedactDSN(dsn)
+	}
+	reps := make([]ReplicaConfig, len(c.Replicas))
+	for i, rep := range c.Replicas {
+		rep.DSN, rep.Password = RedactDSN(rep.DSN), RedactSecret(rep.Password)
+		reps[i] = rep
+	}
+	c.ReplicaDSNs, c.Replicas = dsns, reps
+	return c
 }
 
 // QuickConnect 连接数据库，需要先导入对应驱动
 func (c ConnConfig) QuickConnect(logsql, verbose bool) *xorm.Engine {
-	engine, err := xorm.NewEngine(c.Name(), c.GetDSN(true))
-	if verbose && err != nil {
-		panic(err)
+	dsn, err := c.ResolveDSN(true)
+	if err != nil {
+		if verbose {
+			panic(err)
+		}
+		return nil
+	}
+	engine, err := xorm.NewEngine(c.Name(), dsn)
+	if err != nil {
+		if verbose {
+			msg := strings.ReplaceAll(err.Error(), dsn, RedactDSN(dsn))
+			panic(errors.New(msg))
+		}
+		return nil
 	}
+	c.ApplyPool(engine)
+	AddXormHook(engine, c.Name(), c.Key)
 	if logfile := c.LogFile; logfile != "" && logsql {
 		if strings.Contains(logfile, "") {
 			logfile = strings.Replace(logfile, "$KEY", c.Key, 1)
@@ -0,0 +1,239 @@
+package dialect_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/azhai/xgen/dialect"
+	hcl "github.com/hashicorp/hcl/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecret(t *testing.T) {
+	dialect.ResetSecrets()
+	os.Setenv("XGEN_TEST_PASS", "s3cret")
+	cfg := dialect.ConnConfig{Type: "postgres", Key: "test", Username: "root",
+		Password: "env:XGEN_TEST_PASS", Dialect: &dialect.Postgres{Host: "db", Database: "app"}}
+	assert.Equal(t, "postgres://root:s3cret@db/app", cfg.GetDSN(true))
+	assert.Equal(t, "env:XGEN_TEST_PASS", cfg.Redacted().Password)
+
+	filename := filepath.Join(t.TempDir(), "secret")
+	os.WriteFile(filename, []byte("from-file\n"), 0o600)
+	secret, err := dialect.ResolveSecret("file:" + filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "from-file", secret)
+
+	dialect.RegisterSecretCommands(dialect.SecretCommand{Name: "echo", Command: []string{"echo", "from-cmd"}})
+	secret, err = dialect.ResolveSecret("cmd:echo")
+	assert.NoError(t, err)
+	assert.Equal(t, "from-cmd", secret)
+	_, err = dialect.ResolveSecret("cmd:missing")
+	assert.Error(t, err)
+}
+
+func TestRedactDSN(t *testing.T) {
+	assert.Equal(t, "postgres://root:******@db/app", dialect.RedactDSN("postgres://root:pass@db/app"))
+	assert.Equal(t, "root:******@tcp(db:3306)/app", dialect.RedactDSN("root:pass@tcp(db:3306)/app"))
+	assert.Equal(t, "file:a.db?_auth_user=u&_auth_pass=******", dialect.RedactDSN("file:a.db?_auth_user=u&_auth_pass=p"))
+	assert.Equal(t, "******", dialect.RedactSecret("plain"))
+
+	dsn := "host=db password='a b' dbname=app"
+	assert.Equal(t, "host=db password=****** dbname=app", dialect.RedactDSN(dsn))
+	assert.Equal(t, "password=****** host=db", dialect.RedactDSN("password=x host=db"))
+	cfg := dialect.ConnConfig{Type: "postgres", Key: "app", Dialect: &dialect.Postgres{Host: "db", Database: "app"},
+		ReplicaDSNs: []string{dsn}, Replicas: []dialect.ReplicaConfig{{DSN: "host=r2 password=x"}}}
+	red := cfg.Redacted()
+	assert.NotContains(t, red.ReplicaDSNs[0]+red.Replicas[0].DSN, "a b")
+	assert.NotContains(t, red.Replicas[0].DSN, "password=x")
+}
+
+func TestResolveDSN(t *testing.T) {
+	cfg := dialect.ConnConfig{Type: "mysql", Key: "app", Username: "u", Password: "env:XGEN_MISSING_PASS",
+		Dialect: &dialect.Mysql{Host: "db", Database: "app"}}
+	_, err := cfg.ResolveDSN(true)
+	assert.ErrorContains(t, err, "XGEN_MISSING_PASS")
+	assert.Contains(t, cfg.Validate().Error(), "Unresolvable secret")
+	cfg.Password, cfg.DSN = "", "env:XGEN_MISSING_DSN"
+	_, err = cfg.ResolveDSN(false)
+	assert.Error(t, err)
+	assert.Equal(t, "", cfg.GetDSN(false)) // 不会把引用本身当作DSN
+
+	cfg.DSN, cfg.Password = "", "cmd:xgen-unknown"
+	assert.Contains(t, cfg.Validate().Error(), "not configured")
+	// 检查时只确认命令已配置，不会执行它
+	dialect.RegisterSecretCommands(dialect.SecretCommand{Name: "xgen-false", Command: []string{"false"}})
+	cfg.Password = "cmd:xgen-false"
+	assert.False(t, cfg.Validate().HasErrors())
+}
+
+func TestRepeatConns(t *testing.T) {
+	conns := []dialect.ConnConfig{{Type: "mysql", Key: "main",
+		Dialect: &dialect.Mysql{Host: "db", Database: "main"}}}
+	reps := []dialect.RepeatConfig{{Type: "mysql", Key: "main", DbPrefix: "t_", DbNames: []string{"a", "b"}}}
+	adds := dialect.RepeatConns(reps, conns)
+	assert.Len(t, adds, 2)
+	assert.Equal(t, "t_a", adds[0].Dialect.(*dialect.Mysql).Database)
+	assert.Equal(t, "t_b", adds[1].Dialect.(*dialect.Mysql).Database)
+	assert.Equal(t, "main", conns[0].Dialect.(*dialect.Mysql).Database)
+}
+
+func TestParseDSN(t *testing.T) {
+	cfg, err := dialect.ParseDSN("mysql", "root:p@ss@tcp(db:3307)/shop?charset=utf8mb4&parseTime=true")
+	assert.NoError(t, err)
+	assert.Equal(t, &dialect.Mysql{Host: "db", Port: 3307, Database: "shop"}, cfg.Dialect)
+	assert.Equal(t, "root", cfg.Username)
+	assert.Equal(t, "p@ss", cfg.Password)
+	assert.Equal(t, "utf8mb4", cfg.Options.Get("charset"))
+	assert.Equal(t, "", cfg.Options.Get("parseTime"))
+	assert.Contains(t, cfg.GetDSN(true), "parseTime=true&loc=Local")
+
+	cfg, err = dialect.ParseDSN("mysql", "u:p@tcp(db)/shop?loc=UTC&parseTime=false")
+	assert.NoError(t, err)
+	assert.Equal(t, "u:p@tcp(db)/shop?parseTime=false&loc=UTC", cfg.GetDSN(true))
+	cfg, _ = dialect.ParseDSN("mysql", "u:p@tcp(db)/shop?loc=Asia%2FShanghai")
+	assert.Equal(t, "u:p@tcp(db)/shop?parseTime=true&loc=Asia%2FShanghai", cfg.GetDSN(true))
+
+	cfg, err = dialect.ParseDSN("postgres", "host=pg port=5433 dbname=app user=u password=pw sslmode=disable")
+	assert.NoError(t, err)
+	assert.Equal(t, &dialect.Postgres{Host: "pg", Port: 5433, Database: "app", Sslmode: "disable"}, cfg.Dialect)
+	assert.Equal(t, "postgres://u:pw@pg:5433/app?sslmode=disable", cfg.GetDSN(true))
+
+	cfg, err = dialect.ParseDSN("redis", "redis://:secret@cache:6380/3")
+	assert.NoError(t, err)
+	assert.Equal(t, &dialect.Redis{Host: "cache", Port: 6380, Database: 3}, cfg.Dialect)
+	assert.Equal(t, "secret", cfg.Password)
+
+	cfg, err = dialect.ParseDSN("sqlite", "file:/tmp/a.db?cache=shared&_auth_user=u&_auth_pass=p")
+	assert.NoError(t, err)
+	assert.Equal(t, &dialect.Sqlite{Path: "/tmp/a.db"}, cfg.Dialect)
+	assert.Equal(t, "u", cfg.Username)
+
+	_, err = dialect.ParseDSN("mysql", "no-slash")
+	assert.Error(t, err)
+
+	cfg, err = dialect.ParseDSN("mysql", "u:p@unix(/run/mysqld/mysqld.sock)/shop")
+	assert.NoError(t, err)
+	assert.Equal(t, &dialect.Mysql{Socket: "/run/mysqld/mysqld.sock", Database: "shop"}, cfg.Dialect)
+	assert.Equal(t, "u:p@unix(/run/mysqld/mysqld.sock)/shop?parseTime=true&loc=Local", cfg.GetDSN(true))
+
+	cfg, err = dialect.ParseDSN("postgres", `host=pg password='a b\'c' dbname = app application_name=x\ y`)
+	assert.NoError(t, err)
+	assert.Equal(t, "a b'c", cfg.Password)
+	assert.Equal(t, "app", cfg.Dialect.(*dialect.Postgres).Database)
+	assert.Equal(t, "x y", cfg.Options.Get("application_name"))
+	_, err = dialect.ParseDSN("postgres", "host=pg password='open")
+	assert.Error(t, err)
+
+	cfg, err = dialect.ParseDSN("redis", "rediss://:secret@cache:6380/3")
+	assert.NoError(t, err)
+	assert.True(t, cfg.Dialect.(*dialect.Redis).TLS)
+	assert.Equal(t, "rediss://secret@cache:6380/3", cfg.GetDSN(true))
+
+	// 解析不了的DSN原样使用，只给出警告
+	bad := dialect.ConnConfig{Type: "mysql", Key: "bad", DSN: "u:p@pipe(x)/shop"}
+	diags := bad.Validate()
+	assert.False(t, diags.HasErrors())
+	assert.Contains(t, diags.Error(), "Unparsed DSN")
+	assert.Equal(t, "u:p@pipe(x)/shop", bad.GetDSN(true))
+}
+
+func TestRepeatWithDSN(t *testing.T) {
+	conns := []dialect.ConnConfig{{Type: "postgres", Key: "main", DSN: "postgres://u:pw@pg/main"}}
+	reps := []dialect.RepeatConfig{{Type: "postgres", Key: "main", DbNames: []string{"tenant1"}}}
+	adds := dialect.RepeatConns(reps, conns)
+	assert.Len(t, adds, 1)
+	assert.Equal(t, "postgres://u:pw@pg/tenant1", adds[0].GetDSN(true))
+}
+
+func TestReplicas(t *testing.T) {
+	cfg := dialect.ConnConfig{Type: "mysql", Key: "shop", Username: "u", Password: "p",
+		Dialect:     &dialect.Mysql{Host: "master", Database: "shop"},
+		ReplicaDSNs: []string{"ro:x@tcp(r1:3306)/shop"},
+		Replicas:    []dialect.ReplicaConfig{{Host: "r2", Weight: 3}, {}},
+	}
+	_, _, err := cfg.GetReplicas()
+	assert.Error(t, err)
+	cfg.Replicas = cfg.Replicas[:1]
+	reps, weights, err := cfg.GetReplicas()
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 3}, weights)
+	assert.Equal(t, "ro:x@tcp(r1:3306)/shop?parseTime=true&loc=Local", reps[0].GetDSN(true))
+	assert.Equal(t, "u:p@tcp(r2)/shop?parseTime=true&loc=Local", reps[1].GetDSN(true))
+	assert.Equal(t, "master", cfg.Dialect.(*dialect.Mysql).Host)
+}
+
+func TestDiscoverRepeats(t *testing.T) {
+	conns := []dialect.ConnConfig{{Type: "mysql", Key: "shop",
+		Dialect: &dialect.Mysql{Host: "db", Database: "shop"}}}
+	reps := []dialect.RepeatConfig{{Type: "mysql", Key: "shop", DbPrefix: "shop_",
+		DbNames: []string{"t1"}, DbPattern: `/^shop_t\d+$/`}}
+	list := func(cfg dialect.ConnConfig) ([]string, error) {
+		return []string{"mysql", "shop", "shop_t1", "shop_t2", "shop_tx"}, nil
+	}
+	assert.NoError(t, dialect.DiscoverRepeats(reps, conns, list))
+	assert.Equal(t, []string{"t1", "t2"}, reps[0].DbNames)
+
+	calls := 0
+	failing := func(cfg dialect.ConnConfig) ([]string, error) {
+		if calls++; calls == 1 {
+			return nil, fmt.Errorf("access denied")
+		}
+		return []string{"shop_t3", "shop_shop"}, nil
+	}
+	conns = append(conns, dialect.ConnConfig{Type: "mysql", Key: "shop2",
+		Dialect: &dialect.Mysql{Host: "db2", Database: "shop"}})
+	reps = []dialect.RepeatConfig{{Type: "mysql", Key: "shop", DbPattern: "shop_*"},
+		{Type: "mysql", Key: "shop2", DbPrefix: "shop_", DbPattern: "shop_*"}}
+	diags := dialect.DiscoverRepeats(reps, conns, failing).(hcl.Diagnostics)
+	assert.Len(t, diags, 2)
+	assert.Contains(t, diags[0].Detail, "access denied")
+	assert.Equal(t, hcl.DiagWarning, diags[1].Severity)
+	assert.Contains(t, diags[1].Detail, `collides with the conn key "shop"`)
+	assert.Equal(t, []string{"t3"}, reps[1].DbNames) // 第一个出错后继续处理
+
+	names, err := dialect.RepeatConfig{DbPattern: "shop_t?"}.MatchDbNames([]string{"shop_t1", "shop_tx", "shop_t10"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"shop_t1", "shop_tx"}, names)
+}
+
+func TestRedisMode(t *testing.T) {
+	cfg := dialect.ConnConfig{Type: "redis", Key: "cache", Dialect: &dialect.Redis{Mode: "sentinel",
+		MasterName: "mymaster", SentinelPassword: "env:SENTINEL_PASS"}}
+	diags := cfg.Validate()
+	assert.True(t, diags.HasErrors())
+	assert.Contains(t, diags.Error(), "sentinels")
+	assert.Equal(t, "env:SENTINEL_PASS", cfg.Redacted().Dialect.(*dialect.Redis).SentinelPassword)
+	cfg.Dialect.(*dialect.Redis).SentinelPassword = "plain"
+	assert.Equal(t, "******", cfg.Redacted().Dialect.(*dialect.Redis).SentinelPassword)
+	assert.Equal(t, "plain", cfg.Dialect.(*dialect.Redis).SentinelPassword)
+
+	cfg.Dialect = &dialect.Redis{Mode: "cluster", Nodes: []string{"10.0.0.1:7000"}, Database: 1}
+	assert.Contains(t, cfg.Validate().Error(), "only has database 0")
+	cfg.Dialect = &dialect.Redis{Mode: "cluster", Nodes: []string{"10.0.0.1:7000"}}
+	assert.False(t, cfg.Validate().HasErrors())
+	cfg.Dialect = &dialect.Redis{Mode: "master"}
+	assert.Contains(t, cfg.Validate().Error(), "Invalid redis mode")
+	assert.Equal(t, dialect.REDIS_MODE_STANDALONE, dialect.Redis{}.GetMode())
+}
+
+func TestCacheConfig(t *testing.T) {
+	conns := []dialect.ConnConfig{{Type: "sqlite", Key: "default", Dialect: &dialect.Sqlite{Path: "/tmp/test.db"},
+		Cache: &dialect.CacheConfig{Conn: "cache", Tables: []string{"user"}}}}
+	assert.Contains(t, dialect.ValidateConns(nil, conns).Error(), "not a redis conn")
+	conns = append(conns, dialect.ConnConfig{Type: "redis", Key: "cache", Dialect: &dialect.Redis{Host: "127.0.0.1"}})
+	assert.False(t, dialect.ValidateConns(nil, conns).HasErrors())
+	conns[0].Cache.Codec = "xml"
+	assert.Contains(t, dialect.ValidateConns(nil, conns).Error(), "Invalid cache codec")
+	conns[0].Cache.Codec, conns[0].Cache.Compress = "msgpack", "zstd"
+	assert.False(t, dialect.ValidateConns(nil, conns).HasErrors())
+	conns[0].Cache.Compress = "lz4"
+	assert.Contains(t, dialect.ValidateConns(nil, conns).Error(), "Invalid cache compress")
+
+	cache := conns[0].Cache
+	assert.True(t, cache.HasTable("user"))
+	assert.False(t, cache.HasTable("role"))
+	assert.Equal(t, dialect.CACHE_DEFAULT_TIMEOUT, cache.GetTimeout())
+	assert.True(t, dialect.CacheConfig{}.HasTable("role"))
+}
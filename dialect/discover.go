@@ -0,0 +1,116 @@
+package dialect
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	hcl "github.com/hashicorp/hcl/v2"
+)
+
+// DbLister 列出服务器上的所有数据库
+type DbLister func(cfg ConnConfig) ([]string, error)
+
+// MatchDbNames 找出符合 db_pattern 的数据库，返回去掉 db_prefix 后的名称
+func (r RepeatConfig) MatchDbNames(databases []string) ([]string, error) {
+	match, err := compileDbPattern(r.DbPattern)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, db := range databases {
+		if !strings.HasPrefix(db, r.DbPrefix) || !match(db) {
+			continue
+		}
+		if name := db[len(r.DbPrefix):]; name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// compileDbPattern 两边有斜杠的是正则式，否则是通配符
+func compileDbPattern(pattern string) (func(string) bool, error) {
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		reg, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return nil, err
+		}
+		return reg.MatchString, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid db_pattern %s: %w", pattern, err)
+	}
+	return func(name string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}, nil
+}
+
+// DiscoverRepeats 连接服务器，将符合 db_pattern 的数据库加入 db_names
+// 某个复制配置出错时继续处理其他的，和已有连接重名的数据库会跳过并给出警告
+// 返回的错误是 hcl.Diagnostics ，没有任何诊断信息时为nil
+func DiscoverRepeats(reps []RepeatConfig, conns []ConnConfig, list DbLister) error {
+	var diags hcl.Diagnostics
+	keys := make(map[string]bool, len(conns))
+	for _, cfg := range conns {
+		keys[cfg.Key] = true
+	}
+	for i, rep := range reps {
+		if rep.DbPattern == "" {
+			continue
+		}
+		for _, cfg := range conns {
+			if cfg.Type != rep.Type || cfg.Key != rep.Key {
+				continue
+			}
+			databases, err := list(cfg)
+			if err != nil {
+				diags = diags.Append(&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Cannot list databases",
+					Detail:   fmt.Sprintf("The repeat %q cannot list databases of %q: %s.", rep.Type, cfg.Key, err),
+					Subject:  rep.DefRange().Ptr(),
+				})
+				continue
+			}
+			names, err := rep.MatchDbNames(databases)
+			if err != nil {
+				diags = diags.Append(&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid db_pattern",
+					Detail:   fmt.Sprintf("The repeat %q has a bad db_pattern: %s.", rep.Type, err),
+					Subject:  rep.DefRange().Ptr(),
+				})
+				continue
+			}
+			for _, name := range names {
+				if keys[name] { // 避免和已有的连接重名
+					diags = diags.Append(&hcl.Diagnostic{
+						Severity: hcl.DiagWarning,
+						Summary:  "Database skipped",
+						Detail: fmt.Sprintf("The database %q of repeat %q collides with the conn key %q.",
+							rep.DbPrefix+name, rep.Type, name),
+						Subject: rep.DefRange().Ptr(),
+					})
+					continue
+				}
+				reps[i].DbNames = appendUnique(reps[i].DbNames, name)
+			}
+		}
+	}
+	if len(diags) == 0 {
+		return nil
+	}
+	return diags
+}
+
+func appendUnique(lst []string, item string) []string {
+	for _, x := range lst {
+		if x == item {
+			return lst
+		}
+	}
+	return append(lst, item)
+}
@@ -0,0 +1,121 @@
+package dialect
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"xorm.io/xorm"
+	"xorm.io/xorm/contexts"
+)
+
+const HOOK_MAX_SUMMARY = 200 // 参数摘要的最大长度
+
+var (
+	commandHooks []CommandHook
+	hookLock     sync.RWMutex
+)
+
+// CommandEvent 一条redis命令或SQL语句的执行情况
+type CommandEvent struct {
+	Driver   string // 驱动名，如 redis mysql
+	ConnKey  string // 连接名，即 conn 的第二个标签
+	Command  string // redis命令，或SQL的第一个单词，都是大写
+	Summary  string // 脱敏后的参数摘要，不含具体的值
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// CommandHook 观察每一条命令，Before返回的ctx会传给After
+type CommandHook interface {
+	BeforeCommand(ctx context.Context, evt *CommandEvent) context.Context
+	AfterCommand(ctx context.Context, evt *CommandEvent)
+}
+
+// RegisterCommandHooks 注册全局的命令钩子，对已经建立的连接也生效
+func RegisterCommandHooks(hooks ...CommandHook) {
+	hookLock.Lock()
+	defer hookLock.Unlock()
+	commandHooks = append(commandHooks, hooks...)
+}
+
+// ResetCommandHooks 清除所有命令钩子
+func ResetCommandHooks() {
+	hookLock.Lock()
+	defer hookLock.Unlock()
+	commandHooks = nil
+}
+
+// HasCommandHooks 是否有命令钩子，没有时不必准备 CommandEvent
+func HasCommandHooks() bool {
+	hookLock.RLock()
+	defer hookLock.RUnlock()
+	return len(commandHooks) > 0
+}
+
+// StartCommand 依次调用钩子的Before，返回的finish在命令结束时调用
+func StartCommand(ctx context.Context, evt *CommandEvent) (context.Context, func(err error)) {
+	hookLock.RLock()
+	hooks := append([]CommandHook{}, commandHooks...)
+	hookLock.RUnlock()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	evt.Start = time.Now()
+	for _, h := range hooks {
+		ctx = h.BeforeCommand(ctx, evt)
+	}
+	return ctx, func(err error) {
+		evt.Duration, evt.Err = time.Since(evt.Start), err
+		for i := len(hooks) - 1; i >= 0; i-- {
+			hooks[i].AfterCommand(ctx, evt)
+		}
+	}
+}
+
+// SummarizeSQL SQL语句的第一个单词和摘要，参数只保留个数
+func SummarizeSQL(query string, args []any) (string, string) {
+	query = strings.Join(strings.Fields(query), " ")
+	command, _, _ := strings.Cut(query, " ")
+	if len(query) > HOOK_MAX_SUMMARY {
+		query = query[:HOOK_MAX_SUMMARY] + "..."
+	}
+	if len(args) > 0 {
+		query += fmt.Sprintf(" [%d args]", len(args))
+	}
+	return strings.ToUpper(command), query
+}
+
+type xormFinishKey struct{}
+
+// xormHook 将xorm的钩子转为 CommandHook
+type xormHook struct {
+	driver, key string
+}
+
+// AddXormHook 让xorm引擎的每条SQL都经过命令钩子，QuickConnect 已经调用
+func AddXormHook(engine *xorm.Engine, driver, key string) {
+	engine.AddHook(xormHook{driver: driver, key: key})
+}
+
+func (h xormHook) BeforeProcess(c *contexts.ContextHook) (context.Context, error) {
+	if !HasCommandHooks() {
+		return c.Ctx, nil
+	}
+	evt := &CommandEvent{Driver: h.driver, ConnKey: h.key}
+	evt.Command, evt.Summary = SummarizeSQL(c.SQL, c.Args)
+	ctx, finish := StartCommand(c.Ctx, evt)
+	return context.WithValue(ctx, xormFinishKey{}, finish), nil
+}
+
+func (h xormHook) AfterProcess(c *contexts.ContextHook) error {
+	if c.Ctx != nil {
+		if finish, ok := c.Ctx.Value(xormFinishKey{}).(func(error)); ok {
+			finish(c.Err)
+		}
+	}
+	return nil
+}
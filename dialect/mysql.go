@@ -2,15 +2,21 @@ package dialect
 
 import (
 	"fmt"
+	"net/url"
+	"strconv"
 )
 
 const MYSQL_DEFAULT_PORT uint16 = 3306
 
 // MySQL或MariaDB数据库
 type Mysql struct {
-	Host     string `hcl:"host" json:"host"`
+	Host     string `hcl:"host,optional" json:"host,omitempty"`
 	Port     uint16 `hcl:"port,optional" json:"port,omitempty"`
 	Database string `hcl:"database,optional" json:"database,omitempty"`
+	Socket   string `hcl:"socket,optional" json:"socket,omitempty"` // unix socket路径，设置后忽略host和port
+
+	Loc       string `hcl:"loc,optional" json:"loc,omitempty"`               // 时区，默认Local
+	ParseTime *bool  `hcl:"parse_time,optional" json:"parse_time,omitempty"` // 默认true
 }
 
 // Name 驱动名
@@ -45,8 +51,18 @@ func (d Mysql) BuildDSN() string {
 	if d.Host != "" {
 		addr = GetAddr(d.Host, d.Port)
 	}
+	parseTime, loc := true, "Local"
+	if d.ParseTime != nil {
+		parseTime = *d.ParseTime
+	}
+	if d.Loc != "" {
+		loc = d.Loc
+	}
 	dsn := fmt.Sprintf("tcp(%s)/%s", addr, d.Database)
-	dsn += "?parseTime=true&loc=Local&"
+	if d.Socket != "" {
+		dsn = fmt.Sprintf("unix(%s)/%s", d.Socket, d.Database)
+	}
+	dsn += "?parseTime=" + strconv.FormatBool(parseTime) + "&loc=" + url.QueryEscape(loc) + "&"
 	return dsn
 }
 
@@ -49,7 +49,7 @@ func (d Postgres) BuildDSN() string {
 	}
 	dsn := fmt.Sprintf("postgres://%s/%s?", addr, d.Database)
 	if d.Sslmode != "" {
-		dsn += "sslmode=" + d.Sslmode
+		dsn += "sslmode=" + d.Sslmode + "&"
 	}
 	return dsn
 }
@@ -8,11 +8,25 @@ import (
 
 const REDIS_DEFAULT_PORT uint16 = 6379
 
+const (
+	REDIS_MODE_STANDALONE = "standalone" // 单机，默认
+	REDIS_MODE_SENTINEL   = "sentinel"   // 哨兵，自动切换主库
+	REDIS_MODE_CLUSTER    = "cluster"    // 集群，按照slot分片
+)
+
 // Redis缓存
 type Redis struct {
-	Host     string `hcl:"host" json:"host"`
+	Host     string `hcl:"host,optional" json:"host,omitempty"`
 	Port     uint16 `hcl:"port,optional" json:"port,omitempty"`
 	Database int    `hcl:"database,optional" json:"database,omitempty"`
+	Mode     string `hcl:"mode,optional" json:"mode,omitempty"`
+	Prefix   string `hcl:"key_prefix,optional" json:"key_prefix,omitempty"` // 键的前缀，多个应用共用一个库时区分
+	TLS      bool   `hcl:"tls,optional" json:"tls,omitempty"`               // 使用TLS连接，即 rediss://
+
+	MasterName       string   `hcl:"master_name,optional" json:"master_name,omitempty"`             // 哨兵监控的主库名
+	Sentinels        []string `hcl:"sentinels,optional" json:"sentinels,omitempty"`                 // 哨兵地址 host:port
+	SentinelPassword string   `hcl:"sentinel_password,optional" json:"sentinel_password,omitempty"` // 哨兵的密码，可以是密钥引用
+	Nodes            []string `hcl:"nodes,optional" json:"nodes,omitempty"`                         // 集群的种子节点 host:port
 }
 
 // Name 驱动名
@@ -35,6 +49,14 @@ func (Redis) QuoteIdent(ident string) string {
 	return WrapWith(ident, "'", "'")
 }
 
+// GetMode 运行模式，为空时是单机
+func (d Redis) GetMode() string {
+	if d.Mode == "" {
+		return REDIS_MODE_STANDALONE
+	}
+	return d.Mode
+}
+
 // ChangeDb 切换数据库
 func (d *Redis) ChangeDb(database string) (bool, error) {
 	db, err := strconv.Atoi(database)
@@ -45,19 +67,27 @@ func (d *Redis) ChangeDb(database string) (bool, error) {
 	return true, nil // 成功
 }
 
+// GetScheme DSN的协议，开启TLS时是rediss
+func (d Redis) GetScheme() string {
+	if d.TLS {
+		return "rediss"
+	}
+	return "redis"
+}
+
 // BuildDSN 生成DSN连接串
 func (d Redis) BuildDSN() string {
 	addr := DIALECT_DEFAULT_HOST
 	if d.Host != "" {
 		addr = GetAddr(d.Host, d.Port)
 	}
-	dsn := fmt.Sprintf("redis://%s/%d?", addr, d.Database)
+	dsn := fmt.Sprintf("%s://%s/%d?", d.GetScheme(), addr, d.Database)
 	return dsn
 }
 
 // BuildFullDSN 生成带账号的完整DSN
 func (d Redis) BuildFullDSN(username, password string) string {
-	dsn, head := d.BuildDSN(), "redis://"
+	dsn, head := d.BuildDSN(), d.GetScheme()+"://"
 	if strings.HasPrefix(dsn, head) {
 		account := GetAccount(username, password)
 		dsn = head + account + "@" + dsn[len(head):]
@@ -0,0 +1,147 @@
+package dialect
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"xorm.io/xorm"
+)
+
+// ReplicaConfig 只读副本，可以只提供DSN，或者只修改主库的地址和账号
+type ReplicaConfig struct {
+	DSN      string `hcl:"dsn,optional" json:"dsn,omitempty"`
+	Host     string `hcl:"host,optional" json:"host,omitempty"`
+	Port     uint16 `hcl:"port,optional" json:"port,omitempty"`
+	Username string `hcl:"username,optional" json:"username,omitempty"`
+	Password string `hcl:"password,optional" json:"password,omitempty"`
+	Weight   int    `hcl:"weight,optional" json:"weight,omitempty"` // 用于weight_*策略
+}
+
+// ApplyPool 设置连接池参数，为0时使用驱动默认值
+func (c ConnConfig) ApplyPool(engine *xorm.Engine) {
+	if engine == nil {
+		return
+	}
+	if c.MaxOpen > 0 {
+		engine.SetMaxOpenConns(c.MaxOpen)
+	}
+	if c.MaxIdle > 0 {
+		engine.SetMaxIdleConns(c.MaxIdle)
+	}
+	if c.ConnMaxLifetime > 0 {
+		engine.SetConnMaxLifetime(time.Duration(c.ConnMaxLifetime) * time.Second)
+	}
+	if c.ConnMaxIdleTime > 0 {
+		engine.SetConnMaxIdleTime(time.Duration(c.ConnMaxIdleTime) * time.Second)
+	}
+}
+
+// GetReplicas 所有只读副本的连接配置，包括replicas列表和replica块
+func (c ConnConfig) GetReplicas() (reps []ConnConfig, weights []int, err error) {
+	items := make([]ReplicaConfig, 0, len(c.ReplicaDSNs)+len(c.Replicas))
+	for _, dsn := range c.ReplicaDSNs {
+		items = append(items, ReplicaConfig{DSN: dsn})
+	}
+	items = append(items, c.Replicas...)
+	for i, item := range items {
+		var rep ConnConfig
+		if rep, err = c.buildReplica(item); err != nil {
+			return
+		}
+		rep.Key = fmt.Sprintf("%s.replica%d", c.Key, i+1)
+		if item.Weight <= 0 {
+			item.Weight = 1
+		}
+		reps, weights = append(reps, rep), append(weights, item.Weight)
+	}
+	return
+}
+
+// buildReplica 根据主库配置生成副本配置
+func (c ConnConfig) buildReplica(item ReplicaConfig) (rep ConnConfig, err error) {
+	rep = c.CopyIt(c.Key)
+	rep.ReplicaDSNs, rep.Replicas = nil, nil
+	if item.DSN != "" {
+		rep.Dialect, rep.DSN = nil, item.DSN
+		rep.Remain = nil // 不再使用主库的地址和账号
+		rep.Username, rep.Password, rep.Options = "", "", nil
+		if rep.LoadDialect() == nil {
+			err = fmt.Errorf("unsupported connection type %s", c.Type)
+			return
+		}
+	} else if item.Host != "" {
+		if !setHostAndPort(rep.LoadDialect(), item.Host, item.Port) {
+			err = fmt.Errorf("the replica of %s must use dsn", c.Key)
+			return
+		}
+	} else {
+		err = fmt.Errorf("the replica of %s needs a dsn or a host", c.Key)
+		return
+	}
+	if item.Username != "" {
+		rep.Username = item.Username
+	}
+	if item.Password != "" {
+		rep.Password = item.Password
+	}
+	return
+}
+
+// setHostAndPort 修改网络数据库的地址和端口
+func setHostAndPort(dia Dialect, host string, port uint16) bool {
+	switch d := dia.(type) {
+	case *Mysql:
+		d.Host, d.Port = host, port
+	case *Postgres:
+		d.Host, d.Port = host, port
+	case *Redis:
+		d.Host, d.Port = host, port
+	default:
+		return false
+	}
+	return true
+}
+
+// CreateGroupPolicy 根据名称创建负载均衡策略，默认轮询
+func CreateGroupPolicy(name string, weights []int) xorm.GroupPolicy {
+	switch strings.ToLower(name) {
+	default:
+		return xorm.RoundRobinPolicy()
+	case "random":
+		return xorm.RandomPolicy()
+	case "weight_random":
+		return xorm.WeightRandomPolicy(weights)
+	case "weight_round_robin":
+		return xorm.WeightRoundRobinPolicy(weights)
+	case "least_conn":
+		return xorm.LeastConnPolicy()
+	}
+}
+
+// QuickConnectGroup 连接主库和只读副本，读操作按策略分配到副本上
+func (c ConnConfig) QuickConnectGroup(logsql, verbose bool) *xorm.EngineGroup {
+	master := c.QuickConnect(logsql, verbose)
+	if master == nil {
+		return nil
+	}
+	reps, weights, err := c.GetReplicas()
+	if verbose && err != nil {
+		panic(err)
+	}
+	var (
+		slaves  []*xorm.Engine
+		actives []int
+	)
+	for i, rep := range reps {
+		if slave := rep.QuickConnect(logsql, verbose); slave != nil {
+			slaves, actives = append(slaves, slave), append(actives, weights[i])
+		}
+	}
+	policy := CreateGroupPolicy(c.ReplicaPolicy, actives)
+	group, err := xorm.NewEngineGroup(master, slaves, policy)
+	if verbose && err != nil {
+		panic(err)
+	}
+	return group
+}
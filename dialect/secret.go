@@ -0,0 +1,173 @@
+package dialect
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SECRET_MASK            = "******"
+	SECRET_ENV_PREFIX      = "env:"  // 从环境变量读取，如 env:PG_PASS
+	SECRET_FILE_PREFIX     = "file:" // 从文件读取，如 file:/run/secrets/db
+	SECRET_CMD_PREFIX      = "cmd:"  // 执行已配置的本地命令，取其输出
+	SECRET_DEFAULT_TIMEOUT = 10      // 命令最大执行时长，单位：秒
+)
+
+var (
+	secretCmds  = make(map[string]SecretCommand)
+	secretCache sync.Map
+	secretLock  sync.RWMutex
+
+	// mysql风格的 user:pass@tcp(host)/db
+	mysqlAccountReg = regexp.MustCompile(`^([^:@/]*):([^@]*)@`)
+	// sqlite风格的 _auth_pass=xxx
+	sqlitePassReg = regexp.MustCompile(`(_auth_pass=)[^&]*`)
+	// postgres关键字风格的 password=xxx 或 password='x y'
+	pgPassReg = regexp.MustCompile(`(?i)(^|\s)(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S*)`)
+)
+
+// SecretCommand 本地命令，其标准输出即为密钥
+type SecretCommand struct {
+	Name    string   `hcl:"name,label" json:"name"`
+	Command []string `hcl:"command" json:"command"`
+	Timeout int      `hcl:"timeout,optional" json:"timeout,omitempty"` // 单位：秒
+}
+
+// RegisterSecretCommands 注册可用于 cmd: 引用的本地命令
+func RegisterSecretCommands(cmds ...SecretCommand) {
+	secretLock.Lock()
+	defer secretLock.Unlock()
+	for _, c := range cmds {
+		secretCmds[c.Name] = c
+	}
+}
+
+// IsSecretRef 是否密钥引用，而不是明文
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, SECRET_ENV_PREFIX) ||
+		strings.HasPrefix(value, SECRET_FILE_PREFIX) ||
+		strings.HasPrefix(value, SECRET_CMD_PREFIX)
+}
+
+// CheckSecretRef 检查引用的格式和目标是否存在，不会执行命令，明文直接通过
+func CheckSecretRef(value string) error {
+	switch {
+	case strings.HasPrefix(value, SECRET_ENV_PREFIX):
+		name := value[len(SECRET_ENV_PREFIX):]
+		if name == "" {
+			return fmt.Errorf("the reference %s has no variable name", value)
+		} else if _, ok := os.LookupEnv(name); !ok {
+			return fmt.Errorf("the environment variable %s is not set", name)
+		}
+	case strings.HasPrefix(value, SECRET_FILE_PREFIX):
+		if _, err := os.Stat(value[len(SECRET_FILE_PREFIX):]); err != nil {
+			return err
+		}
+	case strings.HasPrefix(value, SECRET_CMD_PREFIX):
+		name := value[len(SECRET_CMD_PREFIX):]
+		secretLock.RLock()
+		c, ok := secretCmds[name]
+		secretLock.RUnlock()
+		if !ok || len(c.Command) == 0 {
+			return fmt.Errorf("the secret command named %s is not configured", name)
+		}
+	}
+	return nil
+}
+
+// ResolveSecret 解析密钥引用，明文原样返回，解析结果会缓存
+func ResolveSecret(value string) (string, error) {
+	if !IsSecretRef(value) {
+		return value, nil
+	}
+	if secret, ok := secretCache.Load(value); ok {
+		return secret.(string), nil
+	}
+	secret, err := readSecret(value)
+	if err == nil {
+		secretCache.Store(value, secret)
+	}
+	return secret, err
+}
+
+// ResetSecrets 清空已解析的密钥缓存，用于密钥轮换
+func ResetSecrets() {
+	secretCache.Range(func(key, _ any) bool {
+		secretCache.Delete(key)
+		return true
+	})
+}
+
+func readSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, SECRET_ENV_PREFIX):
+		name := value[len(SECRET_ENV_PREFIX):]
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("the environment variable %s is not set", name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, SECRET_FILE_PREFIX):
+		data, err := os.ReadFile(value[len(SECRET_FILE_PREFIX):])
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default:
+		return runSecretCommand(value[len(SECRET_CMD_PREFIX):])
+	}
+}
+
+func runSecretCommand(name string) (string, error) {
+	secretLock.RLock()
+	c, ok := secretCmds[name]
+	secretLock.RUnlock()
+	if !ok || len(c.Command) == 0 {
+		return "", fmt.Errorf("the secret command named %s is not configured", name)
+	}
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = SECRET_DEFAULT_TIMEOUT
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("the secret command %s failed: %w", name, err)
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
+
+// RedactSecret 隐藏明文密钥，引用则原样保留
+func RedactSecret(value string) string {
+	if value == "" || IsSecretRef(value) {
+		return value
+	}
+	return SECRET_MASK
+}
+
+// RedactDSN 隐藏DSN连接串中的密码
+func RedactDSN(dsn string) string {
+	if dsn == "" {
+		return dsn
+	}
+	if strings.Contains(dsn, "://") {
+		if u, err := url.Parse(dsn); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); ok { // Redacted()使用xxxxx代替密码
+				dsn = strings.Replace(u.Redacted(), ":xxxxx@", ":"+SECRET_MASK+"@", 1)
+			}
+		}
+	} else if pgPassReg.MatchString(dsn) {
+		dsn = pgPassReg.ReplaceAllString(dsn, "${1}${2}"+SECRET_MASK)
+	} else {
+		dsn = mysqlAccountReg.ReplaceAllString(dsn, "${1}:"+SECRET_MASK+"@")
+	}
+	return sqlitePassReg.ReplaceAllString(dsn, "${1}"+SECRET_MASK)
+}
@@ -0,0 +1,268 @@
+package dialect
+
+import (
+	"fmt"
+	"net/url"
+
+	hcl "github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+)
+
+// DecodeDialect 加载数据库驱动配置，并返回解析时的诊断信息
+func (c *ConnConfig) DecodeDialect() (Dialect, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	if c.Type == "" || c.Dialect != nil {
+		return c.Dialect, diags
+	}
+	c.Dialect = CreateDialectByName(c.Type)
+	if c.Dialect == nil {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unsupported connection type",
+			Detail:   fmt.Sprintf("The conn %q has an unknown type %q.", c.Key, c.Type),
+			Subject:  c.DefRange().Ptr(),
+		})
+	} else if c.Remain != nil {
+		diags = gohcl.DecodeBody(c.Remain, nil, c.Dialect)
+	}
+	if c.Dialect != nil && c.DSN != "" {
+		diags = diags.Extend(c.loadFromDSN())
+	}
+	return c.Dialect, diags
+}
+
+// loadFromDSN 将直接提供的DSN解析到驱动配置中，以便切换数据库
+func (c *ConnConfig) loadFromDSN() (diags hcl.Diagnostics) {
+	if _, isSqlite := c.Dialect.(*Sqlite); !isSqlite && IsSecretRef(c.DSN) {
+		return // 引用的DSN在连接时才解析
+	}
+	if _, isFlash := c.Dialect.(*FlashDB); isFlash {
+		return
+	}
+	parsed, err := ParseDSN(c.Type, c.DSN)
+	if err != nil { // 保留DSN原样连接，只是不能切换数据库
+		return diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagWarning,
+			Summary:  "Unparsed DSN",
+			Detail:   fmt.Sprintf("The conn %q has a dsn which can not be parsed, it will be used as is: %s.", c.Key, err),
+			Subject:  c.attrRange("dsn").Ptr(),
+		})
+	}
+	c.Dialect, c.DSN = parsed.Dialect, ""
+	if c.Username == "" {
+		c.Username = parsed.Username
+	}
+	if c.Password == "" {
+		c.Password = parsed.Password
+	}
+	for key, vals := range c.Options { // 配置中的参数优先
+		if parsed.Options == nil {
+			parsed.Options = url.Values{}
+		}
+		parsed.Options[key] = vals
+	}
+	c.Options = parsed.Options
+	return
+}
+
+// DefRange 连接配置在文件中的位置
+func (c ConnConfig) DefRange() hcl.Range {
+	if c.Remain == nil {
+		return hcl.Range{}
+	}
+	return c.Remain.MissingItemRange()
+}
+
+// Validate 检查连接配置，返回带文件位置的诊断信息
+func (c *ConnConfig) Validate() (diags hcl.Diagnostics) {
+	if c.Key == "" {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Missing connection key",
+			Detail:   "Every conn block needs a type label and a key label.",
+			Subject:  c.DefRange().Ptr(),
+		})
+	}
+	hasDSN := c.DSN != ""
+	dia, decDiags := c.DecodeDialect()
+	for _, diag := range decDiags {
+		if hasDSN && diag.Summary == "Missing required argument" {
+			continue // 已经直接提供了DSN
+		}
+		diags = diags.Append(diag)
+	}
+	if dia != nil && !diags.HasErrors() {
+		if _, _, err := c.GetReplicas(); err != nil {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid replica",
+				Detail:   fmt.Sprintf("The conn %q has a bad replica: %s.", c.Key, err),
+				Subject:  c.DefRange().Ptr(),
+			})
+		}
+		diags = diags.Extend(c.validateSecrets())
+	}
+	if dia == nil || hasDSN || diags.HasErrors() {
+		return
+	}
+	if d, ok := dia.(*Redis); ok && d.GetMode() != REDIS_MODE_STANDALONE {
+		return c.validateRedisMode(d)
+	}
+	host, port, ok := hostAndPort(dia)
+	if !ok {
+		return
+	}
+	if host == "" {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Missing host",
+			Detail:   fmt.Sprintf("The conn %q needs a non-empty host.", c.Key),
+			Subject:  c.attrRange("host").Ptr(),
+		})
+	}
+	if rng := c.attrRange("port"); port == 0 && rng != c.DefRange() {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid port",
+			Detail:   fmt.Sprintf("The conn %q has a port which must be between 1 and 65535.", c.Key),
+			Subject:  rng.Ptr(),
+		})
+	}
+	return
+}
+
+// validateSecrets 检查密码和DSN中的引用，包括只读副本，不会真正读取密钥
+func (c ConnConfig) validateSecrets() (diags hcl.Diagnostics) {
+	confs := []ConnConfig{c}
+	if reps, _, err := c.GetReplicas(); err == nil {
+		confs = append(confs, reps...)
+	}
+	for _, conf := range confs {
+		refs := []string{conf.Password}
+		if _, isSqlite := conf.LoadDialect().(*Sqlite); !isSqlite { // sqlite的DSN本身以file:开头
+			refs = append(refs, conf.DSN)
+		}
+		for _, ref := range refs {
+			err := CheckSecretRef(ref)
+			if err == nil {
+				continue
+			}
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Unresolvable secret",
+				Detail:   fmt.Sprintf("The conn %q has a secret which can not be resolved: %s.", conf.Key, err),
+				Subject:  c.DefRange().Ptr(),
+			})
+		}
+	}
+	return
+}
+
+// validateRedisMode 检查哨兵和集群模式需要的参数
+func (c ConnConfig) validateRedisMode(d *Redis) (diags hcl.Diagnostics) {
+	missing := func(name, detail string) {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Missing required argument",
+			Detail:   fmt.Sprintf("The conn %q %s.", c.Key, detail),
+			Subject:  c.attrRange(name).Ptr(),
+		})
+	}
+	switch d.Mode {
+	default:
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid redis mode",
+			Detail: fmt.Sprintf("The conn %q has mode %q, which must be one of %s, %s or %s.", c.Key,
+				d.Mode, REDIS_MODE_STANDALONE, REDIS_MODE_SENTINEL, REDIS_MODE_CLUSTER),
+			Subject: c.attrRange("mode").Ptr(),
+		})
+	case REDIS_MODE_SENTINEL:
+		if d.MasterName == "" {
+			missing("master_name", "needs a master_name in sentinel mode")
+		}
+		if len(d.Sentinels) == 0 {
+			missing("sentinels", "needs at least one address in sentinels")
+		}
+	case REDIS_MODE_CLUSTER:
+		if len(d.Nodes) == 0 {
+			missing("nodes", "needs at least one address in nodes")
+		}
+		if d.Database != 0 {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid database",
+				Detail:   fmt.Sprintf("The conn %q is a redis cluster, which only has database 0.", c.Key),
+				Subject:  c.attrRange("database").Ptr(),
+			})
+		}
+	}
+	return
+}
+
+// attrRange 找出属性的位置，找不到时使用整个配置的位置
+func (c ConnConfig) attrRange(name string) hcl.Range {
+	if c.Remain != nil {
+		schema := &hcl.BodySchema{Attributes: []hcl.AttributeSchema{{Name: name}}}
+		content, _, _ := c.Remain.PartialContent(schema)
+		if attr, ok := content.Attributes[name]; ok {
+			return attr.Expr.Range()
+		}
+	}
+	return c.DefRange()
+}
+
+// hostAndPort 网络数据库的地址和端口
+func hostAndPort(dia Dialect) (string, uint16, bool) {
+	switch d := dia.(type) {
+	case *Mysql:
+		return d.Host, d.Port, d.Socket == ""
+	case *Postgres:
+		return d.Host, d.Port, true
+	case *Redis:
+		return d.Host, d.Port, true
+	}
+	return "", 0, false
+}
+
+// ValidateConns 检查所有连接和复制配置，包括重复的连接名
+func ValidateConns(reps []RepeatConfig, conns []ConnConfig) (diags hcl.Diagnostics) {
+	keys := make(map[string]*ConnConfig)
+	for i := range conns {
+		c := &conns[i]
+		diags = diags.Extend(c.Validate())
+		if first, ok := keys[c.Key]; ok {
+			rng := first.DefRange()
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate connection key",
+				Detail:   fmt.Sprintf("The conn key %q was already defined at %s.", c.Key, rng.String()),
+				Subject:  c.DefRange().Ptr(),
+			})
+		} else if c.Key != "" {
+			keys[c.Key] = c
+		}
+	}
+	for i := range conns {
+		diags = diags.Extend(conns[i].validateCache(keys))
+	}
+	for _, rep := range reps {
+		if _, err := compileDbPattern(rep.DbPattern); rep.DbPattern != "" && err != nil {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid db_pattern",
+				Detail:   fmt.Sprintf("The repeat %q has a bad db_pattern: %s.", rep.Type, err),
+				Subject:  rep.DefRange().Ptr(),
+			})
+		}
+		if c, ok := keys[rep.Key]; !ok || c.Type != rep.Type {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Repeat of missing conn",
+				Detail:   fmt.Sprintf("The repeat %q refers to the conn %q which is not defined.", rep.Type, rep.Key),
+				Subject:  rep.DefRange().Ptr(),
+			})
+		}
+	}
+	return
+}
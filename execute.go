@@ -171,6 +171,9 @@ func (r *Reverser) ExecuteReverse(source dialect.ConnConfig, verbose bool) (bool
 		"AliasName": "models",
 		"Import":    dia.ImporterPath(),
 	}
+	if source.Cache != nil {
+		data["Cache"] = source.Cache
+	}
 	if strings.HasSuffix(r.target.NameSpace, "/models") {
 		data["AliasName"] = ""
 	}
@@ -13,12 +13,16 @@ require (
 	github.com/hashicorp/hcl/v2 v2.23.0
 	github.com/json-iterator/go v1.1.12
 	github.com/k0kubun/pp v3.0.1+incompatible
+	github.com/klauspost/compress v1.18.0
 	github.com/lib/pq v1.10.9
 	github.com/manifoldco/promptui v0.9.0
 	github.com/mattn/go-sqlite3 v1.14.28
 	github.com/mitchellh/copystructure v1.2.0
 	github.com/pkg/errors v0.9.1
+	github.com/shamaton/msgpack/v2 v2.2.0
 	github.com/stretchr/testify v1.10.0
+	github.com/zclconf/go-cty v1.16.2
+	golang.org/x/sync v0.14.0
 	golang.org/x/tools v0.33.0
 	xorm.io/xorm v1.3.9
 )
@@ -48,11 +52,9 @@ require (
 	github.com/onsi/gomega v1.37.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/syndtr/goleveldb v1.0.0 // indirect
-	github.com/zclconf/go-cty v1.16.2 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/zap v1.27.0 // indirect
 	golang.org/x/mod v0.24.0 // indirect
-	golang.org/x/sync v0.14.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
 	golang.org/x/text v0.25.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
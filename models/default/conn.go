@@ -10,18 +10,26 @@ import (
 	"xorm.io/xorm"
 )
 
-var engine *xorm.Engine
+var engine *xorm.EngineGroup
 
-// ConnectXorm 连接数据库
-func ConnectXorm(cfg dialect.ConnConfig) *xorm.Engine {
+// ConnectXorm 连接数据库，配置了只读副本时读写分离
+func ConnectXorm(cfg dialect.ConnConfig) *xorm.EngineGroup {
 	if d := cfg.LoadDialect(); d == nil || !d.IsXormDriver() {
 		return nil
 	}
-	return cfg.QuickConnect(true, true)
+	return cfg.QuickConnectGroup(true, true)
 }
 
-// Engine 获取当前数据库连接
+// Engine 获取主库连接
 func Engine() *xorm.Engine {
+	if eg := EngineGroup(); eg != nil {
+		return eg.Master()
+	}
+	return nil
+}
+
+// EngineGroup 获取主库和只读副本，读操作会分配到只读副本
+func EngineGroup() *xorm.EngineGroup {
 	if engine == nil {
 		cfg := models.GetConnConfig("default")
 		engine = ConnectXorm(cfg)
@@ -32,7 +40,7 @@ func Engine() *xorm.Engine {
 
 // Query 生成查询
 func Query(opts ...xq.QueryOption) *xorm.Session {
-	qr := Engine().NewSession()
+	qr := EngineGroup().NewSession()
 	if len(opts) > 0 {
 		return xq.ApplyOptions(qr, opts)
 	}
@@ -44,17 +52,22 @@ func Quote(value string) string {
 	return Engine().Quote(value)
 }
 
-// InsertBatch 写入多行数据
+// InsertBatch 写入多行数据，每行是一个对象或者map
 func InsertBatch(tableName string, rows ...any) error {
 	if len(rows) == 0 {
 		return nil
 	}
 	modify := func(tx *xorm.Session) (int64, error) {
-		return tx.Table(tableName).Insert(rows)
+		return tx.Table(tableName).Insert(rows...)
 	}
 	return xq.ExecTx(Engine(), modify)
 }
 
+// InsertMaps 用一条语句写入多行map数据
+func InsertMaps(tableName string, rows []map[string]any) error {
+	return xq.InsertMaps(Engine(), tableName, rows)
+}
+
 // UpdateBatch 更新多行数据
 func UpdateBatch(tableName, pkey string, ids any, changes map[string]any) error {
 	if len(changes) == 0 || ids == nil {
@@ -67,7 +80,7 @@ func UpdateBatch(tableName, pkey string, ids any, changes map[string]any) error
 }
 
 // SyncModels 同步数据库表结构
-func SyncModels(eng *xorm.Engine) error {
+func SyncModels(eng *xorm.EngineGroup) error {
 	if eng == nil {
 		return fmt.Errorf("the connection is lost")
 	}
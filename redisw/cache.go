@@ -19,6 +19,7 @@ func NewMap() Map {
 	return make(Map)
 }
 
+// Map2Args 转为命令参数，asJson为true时总是编码为Json，需要包装器的编码时使用 EncodeArgs
 func Map2Args(data Map, asJson bool) []any {
 	var args []any
 	for key, val := range data {
@@ -111,26 +112,32 @@ func ExecMapFloat(exec ExecMulti, keys ...string) (map[string]float64, error) {
 // / redis string 的方法                                   ///
 // //////////////////////////////////////////////////////////
 
+// SaveJson 和 SaveValue 相同，设置了 Serializer 时不再是Json
 func (r *RedisWrapper) SaveJson(key string, obj any, timeout int) (bool, error) {
-	value, err := json.Marshal(obj)
-	if err != nil {
-		return false, err
-	}
-	return r.SetVal(key, value, timeout)
+	return r.SaveValue(key, obj, timeout)
 }
 
+// SaveMap asJson为true时按照包装器的编码，默认是Json
 func (r *RedisWrapper) SaveMap(data Map, asJson bool) (bool, error) {
-	args := Map2Args(data, asJson)
+	args, err := r.mapArgs(data, asJson)
+	if err != nil {
+		return false, err
+	}
 	reply, err := r.Exec("MSET", args...)
 	return ReplyBool(reply, err)
 }
 
+// LoadJson 和 LoadValue 相同，读取 SaveJson 保存的对象
 func (r *RedisWrapper) LoadJson(key string, obj any) error {
-	value, err := r.GetBytes(key)
-	if err != nil {
-		return err
+	return r.LoadValue(key, obj)
+}
+
+// mapArgs asJson为true时使用 EncodeArgs ，否则和 Map2Args 相同
+func (r *RedisWrapper) mapArgs(data Map, asJson bool) ([]any, error) {
+	if asJson {
+		return r.EncodeArgs(data)
 	}
-	return json.Unmarshal(value, obj)
+	return Map2Args(data, false), nil
 }
 
 func (r *RedisWrapper) OrigMulti(cmd string, keys ...any) (any, error) {
@@ -160,8 +167,12 @@ func (r *RedisWrapper) LoadMapInt(keys ...string) (map[string]int, error) {
 // / redis hash 的方法                                     ///
 // //////////////////////////////////////////////////////////
 
+// SaveMap asJson为true时按照包装器的编码，默认是Json
 func (rh *RedisHash) SaveMap(data Map, asJson bool) (bool, error) {
-	args := Map2Args(data, asJson)
+	args, err := rh.RedisWrapper.mapArgs(data, asJson)
+	if err != nil {
+		return false, err
+	}
 	defer rh.Exec("EXPIRE", rh.timeout)
 	return ReplyBool(rh.Exec("HMSET", args...))
 }
@@ -193,27 +204,48 @@ func (rh *RedisHash) LoadMapInt(keys ...string) (map[string]int, error) {
 // / redis string 和 hash 协作的方法                        ///
 // //////////////////////////////////////////////////////////
 
-// SaveForeignData 基本类型保存于自身，CacheData数据关联保存为Json
-func (rh *RedisHash) SaveForeignData(data Map) (ok bool, err error) {
+// SaveForeignData 基本类型保存于自身，CacheData数据按照包装器的编码关联保存，在同一个事务中写入
+// 哈希表永不过期时，关联的数据也永不过期
+func (rh *RedisHash) SaveForeignData(data Map) (bool, error) {
 	summary, timeout := NewMap(), rh.GetTimeout(true)
-	for key, val := range data {
-		if val == nil {
-			continue
-		}
-		if obj, ok := val.(CacheData); ok {
-			id := obj.GetCacheId()
-			if id != "" && err == nil {
-				ok, err = rh.RedisWrapper.SaveJson(id, val, timeout)
+	serializer := rh.GetSerializer()
+	replies, err := rh.RedisWrapper.Tx(func(p *Pipeline) error {
+		for key, val := range data {
+			if val == nil {
+				continue
+			}
+			if obj, ok := val.(CacheData); ok {
+				id := obj.GetCacheId()
+				if id == "" {
+					continue
+				}
+				value, err := serializer.Encode(val)
+				if err != nil {
+					return err
+				}
+				if timeout > 0 {
+					p.Send("SETEX", id, timeout, value)
+				} else {
+					p.Send("SET", id, value)
+				}
 				summary[key] = id
+			} else {
+				summary[key] = val
 			}
-		} else {
-			summary[key] = val
 		}
-	}
+		if len(summary) > 0 {
+			args := append([]any{rh.name}, Map2Args(summary, false)...)
+			p.Send("HMSET", args...)
+			if rh.timeout > 0 {
+				p.Send("EXPIRE", rh.name, rh.timeout)
+			}
+		}
+		return nil
+	})
 	if err == nil {
-		ok, err = rh.SaveMap(summary, false)
+		err = replies.Err()
 	}
-	return
+	return err == nil, err
 }
 
 func (rh *RedisHash) LoadSummary(data Map) (map[string]string, error) {
@@ -228,7 +260,7 @@ func (rh *RedisHash) LoadSummary(data Map) (map[string]string, error) {
 	return rh.LoadMapString(keys...)
 }
 
-// LoadForeignJson 只能得到CacheData数据的Map，基本类型需要自己加载
+// LoadForeignJson 只能得到CacheData数据的Map，基本类型需要自己加载，按照包装器的编码解码
 func (rh *RedisHash) LoadForeignJson(data Map) (err error) {
 	var summary map[string]string
 	if summary, err = rh.LoadSummary(data); err != nil {
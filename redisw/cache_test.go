@@ -1,6 +1,7 @@
 package redisw_test
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -12,13 +13,20 @@ import (
 
 var cfg = dialect.ConnConfig{Type: "redis", Key: "test"}
 
-func GetRedis() *redisw.RedisWrapper {
-	return redisw.NewRedisPool(cfg, 0)
+// GetRedis 连接本机的redis，连不上时跳过测试，替身不支持的命令才需要真实的redis
+func GetRedis(t *testing.T) *redisw.RedisWrapper {
+	r := redisw.NewRedisPool(cfg, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := r.ExecContext(ctx, "PING"); err != nil {
+		t.Skipf("the redis %s is unreachable: %v", cfg.GetDSN(false), err)
+	}
+	return r
 }
 
 func TestConn(t *testing.T) {
 	t.Log("DSN:", cfg.GetDSN(false))
-	r := GetRedis()
+	r := GetRedis(t)
 	reply, err := r.Exec("PING")
 	assert.NoError(t, err)
 	result := reply.(string)
@@ -28,25 +36,28 @@ func TestConn(t *testing.T) {
 
 func TestInt(t *testing.T) {
 	name := "test:a"
-	r := GetRedis()
+	r := redisw.NewRedisFake()
+	fake := r.RedisContainer.(*redisw.FakeContainer)
 	r.SetVal(name, 39, 60)
 	assert.Equal(t, 60, r.GetTimeout(name))
 	a, err := r.GetInt(name)
 	assert.NoError(t, err)
 	assert.Equal(t, 39, a)
-	time.Sleep(2 * time.Second)
+	fake.Advance(2 * time.Second)
 	assert.Equal(t, 58, r.GetTimeout(name))
 }
 
 func TestHash(t *testing.T) {
 	name, key := "test:hash", "a"
-	rh := redisw.NewRedisHash(GetRedis(), name, 2)
+	r := redisw.NewRedisFake()
+	fake := r.RedisContainer.(*redisw.FakeContainer)
+	rh := redisw.NewRedisHash(r, name, 2)
 	rh.SetVal(key, 40)
 	assert.Equal(t, 2, rh.GetTimeout(false))
 	a, err := rh.GetInt(key)
 	assert.NoError(t, err)
 	assert.Equal(t, 40, a)
-	time.Sleep(2 * time.Second)
+	fake.Advance(2 * time.Second)
 	assert.Equal(t, -2, rh.GetTimeout(false))
 }
 
@@ -100,7 +111,7 @@ var ryan = NewProfile(5, 40,
 
 func TestJson(t *testing.T) {
 	key := "test:name"
-	r := GetRedis()
+	r := redisw.NewRedisFake()
 	ok, err := r.SaveJson(key, ryan.RealName, 60)
 	assert.True(t, ok)
 	assert.NoError(t, err)
@@ -119,7 +130,7 @@ func TestCache(t *testing.T) {
 		"name": ryan.RealName,
 		"addr": ryan.Address,
 	}
-	rh := redisw.NewRedisHash(GetRedis(), "profile:5", 60)
+	rh := redisw.NewRedisHash(redisw.NewRedisFake(), "profile:5", 60)
 	ok, err := rh.SaveForeignData(data)
 	assert.True(t, ok)
 	assert.NoError(t, err)
@@ -144,3 +155,61 @@ func TestCache(t *testing.T) {
 	}
 	assert.Equal(t, street, ryan.Address.Street)
 }
+
+func TestListSetZSet(t *testing.T) {
+	r := redisw.NewRedisFake()
+	rl := redisw.NewRedisList(r, "test:list", 60)
+	rl.DeleteAll()
+	n, err := rl.PushStrings("a", "b", "c")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	first, _ := rl.PopString(true)
+	assert.Equal(t, "a", first)
+	items, _ := rl.GetAllString()
+	assert.Equal(t, []string{"b", "c"}, items)
+
+	rs, other := redisw.NewRedisSet(r, "test:set", 60), redisw.NewRedisSet(r, "test:set2", 60)
+	rs.DeleteAll()
+	other.DeleteAll()
+	rs.AddStrings("a", "b", "c")
+	other.AddStrings("b", "c", "d")
+	inter, _ := rs.Inter("test:set2")
+	assert.ElementsMatch(t, []string{"b", "c"}, inter)
+	union, _ := rs.Union("test:set2")
+	assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, union)
+	diff, _ := rs.Diff("test:set2")
+	assert.Equal(t, []string{"a"}, diff)
+	ok, _ := rs.IsMember("a")
+	assert.True(t, ok)
+
+	rz := redisw.NewRedisZSet(r, "test:zset", 60)
+	rz.DeleteAll()
+	rz.AddMap(map[string]float64{"a": 10, "b": 30, "c": 20})
+	top, err := rz.GetTop(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []redisw.ZMember{{"b", 30}, {"c", 20}}, top)
+	rank, _ := rz.GetRank("a", true)
+	assert.Equal(t, 2, rank)
+}
+
+func TestFakeCache(t *testing.T) {
+	data := redisw.Map{"age": ryan.Age, "name": ryan.RealName, "addr": ryan.Address}
+	rh := redisw.NewRedisHash(redisw.NewRedisFake(), "profile:5", 60)
+	ok, err := rh.SaveForeignData(data)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	data["addr"] = &Address{}
+	err = rh.LoadForeignJson(data)
+	assert.NoError(t, err)
+	assert.Equal(t, ryan.Address.Street, data["addr"].(*Address).Street)
+	age, err := rh.GetInt("age")
+	assert.NoError(t, err)
+	assert.Equal(t, ryan.Age, age)
+
+	forever := redisw.NewRedisHash(rh.RedisWrapper, "profile:6", 0)
+	ok, err = forever.SaveForeignData(redisw.Map{"age": 1, "addr": ryan.Address})
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Equal(t, -1, forever.GetTimeout(false))
+	assert.Equal(t, -1, rh.RedisWrapper.GetTimeout(ryan.Address.GetCacheId()))
+}
@@ -0,0 +1,132 @@
+package redisw
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+
+	"github.com/azhai/xgen/utils"
+	"github.com/gomodule/redigo/redis"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	CACHED_DEFAULT_NEG_TIMEOUT = 30  // 空结果缓存时长，单位：秒
+	CACHED_DEFAULT_JITTER      = 0.1 // 缓存时长随机增加的比例
+)
+
+// ErrNotFound loader找不到数据时返回，结果会被短暂缓存
+var ErrNotFound = errors.New("the cached data is not found")
+
+// cachedEntry 缓存的内容，Missing表示空结果
+type cachedEntry[T any] struct {
+	Value   T    `json:"v"`
+	Missing bool `json:"m,omitempty"`
+}
+
+// Cached 旁路缓存，同一个键的并发加载只执行一次
+type Cached[T any] struct {
+	prefix     string
+	timeout    int
+	NegTimeout int     // 空结果缓存时长
+	Jitter     float64 // 缓存时长随机增加的比例，避免同时过期
+	group      singleflight.Group
+	*RedisWrapper
+}
+
+// NewCached 创建旁路缓存，键名会加上前缀，timeout不大于0时永不过期
+func NewCached[T any](r *RedisWrapper, prefix string, timeout int) *Cached[T] {
+	return &Cached[T]{
+		RedisWrapper: r, prefix: prefix, timeout: timeout,
+		NegTimeout: CACHED_DEFAULT_NEG_TIMEOUT, Jitter: CACHED_DEFAULT_JITTER,
+	}
+}
+
+// GetKey 完整的键名
+func (c *Cached[T]) GetKey(key string) string {
+	return utils.ConcatWith(c.prefix, key)
+}
+
+// GetTimeout 加上随机抖动的缓存时长
+func (c *Cached[T]) GetTimeout() int {
+	timeout := c.timeout
+	if span := int(float64(timeout) * c.Jitter); span > 0 {
+		timeout += rand.Intn(span + 1)
+	}
+	return timeout
+}
+
+// Get 先读缓存，没有时调用loader加载并写入缓存
+func (c *Cached[T]) Get(ctx context.Context, key string, loader func() (T, error)) (T, error) {
+	var zero T
+	if entry, ok, err := c.load(key); err != nil {
+		return zero, err
+	} else if ok {
+		if entry.Missing {
+			return zero, ErrNotFound
+		}
+		return entry.Value, nil
+	}
+	ch := c.group.DoChan(key, func() (any, error) {
+		return c.fill(key, loader)
+	})
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return zero, res.Err
+		}
+		value, _ := res.Val.(T)
+		return value, nil
+	}
+}
+
+// Invalidate 删除缓存，下次Get时重新加载
+func (c *Cached[T]) Invalidate(keys ...string) (int, error) {
+	fullKeys := make([]string, len(keys))
+	for i, key := range keys {
+		fullKeys[i] = c.GetKey(key)
+		c.group.Forget(key)
+	}
+	return c.Delete(fullKeys...)
+}
+
+// load 读取缓存，不存在或内容损坏时ok为false，Redis出错时返回错误
+func (c *Cached[T]) load(key string) (entry cachedEntry[T], ok bool, err error) {
+	value, err := c.GetBytes(c.GetKey(key))
+	if errors.Is(err, redis.ErrNil) {
+		return entry, false, nil
+	} else if err != nil {
+		return entry, false, err
+	}
+	if err = c.GetSerializer().Decode(value, &entry); err != nil {
+		return entry, false, nil // 解码失败当作未命中，包括更换编码之前保存的内容
+	}
+	return entry, true, nil
+}
+
+// fill 调用loader并写入缓存，写缓存失败不影响结果
+func (c *Cached[T]) fill(key string, loader func() (T, error)) (T, error) {
+	if entry, ok, err := c.load(key); err != nil {
+		var zero T
+		return zero, err
+	} else if ok { // 等待期间已被其他进程写入
+		if entry.Missing {
+			return entry.Value, ErrNotFound
+		}
+		return entry.Value, nil
+	}
+	value, err := loader()
+	if errors.Is(err, ErrNotFound) {
+		if c.NegTimeout > 0 {
+			missing := cachedEntry[T]{Missing: true}
+			_, _ = c.SaveJson(c.GetKey(key), missing, c.NegTimeout)
+		}
+		return value, ErrNotFound
+	} else if err != nil {
+		return value, err
+	}
+	_, _ = c.SaveJson(c.GetKey(key), cachedEntry[T]{Value: value}, c.GetTimeout())
+	return value, nil
+}
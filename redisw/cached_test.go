@@ -0,0 +1,67 @@
+package redisw_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/azhai/xgen/redisw"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCached(t *testing.T) {
+	var calls int32
+	cached := redisw.NewCached[RealName](redisw.NewRedisFake(), "test:cached", 60)
+	cached.Invalidate("5", "404")
+	loader := func() (RealName, error) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(100 * time.Millisecond)
+		return *ryan.RealName, nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			name, err := cached.Get(context.Background(), "5", loader)
+			assert.NoError(t, err)
+			assert.Equal(t, "Ryan", name.FirstName)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	missing := func() (RealName, error) {
+		atomic.AddInt32(&calls, 1)
+		return RealName{}, redisw.ErrNotFound
+	}
+	_, err := cached.Get(context.Background(), "404", missing)
+	assert.ErrorIs(t, err, redisw.ErrNotFound)
+	_, err = cached.Get(context.Background(), "404", missing)
+	assert.ErrorIs(t, err, redisw.ErrNotFound)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestFakeCached(t *testing.T) {
+	r := redisw.NewRedisFake()
+	cached := redisw.NewCached[RealName](r, "test:cached", 0)
+	calls := 0
+	loader := func() (RealName, error) {
+		calls++
+		return *ryan.RealName, nil
+	}
+	name, err := cached.Get(context.Background(), "5", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, "Ryan", name.FirstName)
+	assert.Equal(t, -1, r.GetTimeout("test:cached:5")) // 永不过期
+	name, err = cached.Get(context.Background(), "5", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+
+	r.Exec("HSET", "test:cached:6", "x", 1) // WRONGTYPE不能当作未命中
+	_, err = cached.Get(context.Background(), "6", loader)
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,497 @@
+package redisw
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/azhai/xgen/dialect"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	REDIS_CLUSTER_SLOTS         = 16384 // 集群的slot数量
+	REDIS_CLUSTER_MAX_REDIRECTS = 5     // MOVED和ASK最多跳转几次
+)
+
+var TooManyRedirectsError = errors.New("too many MOVED or ASK redirects in redis cluster")
+
+// crc16 XMODEM校验，用于计算slot
+func crc16(data string) uint16 {
+	var crc uint16
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// hashTag 键中第一个非空的 {tag}
+func hashTag(key string) (string, bool) {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return key[start+1 : start+1+end], true
+		}
+	}
+	return "", false
+}
+
+// KeySlot 键所在的slot，有 {tag} 时只计算tag部分
+func KeySlot(key string) int {
+	if tag, ok := hashTag(key); ok {
+		key = tag
+	}
+	return int(crc16(key) % REDIS_CLUSTER_SLOTS)
+}
+
+// SlotTag 用作其他键名的一部分，使那个键在集群中和key落在同一个slot，加前缀后也成立
+// key已有 {tag} 时原样返回，否则把加了前缀的key整个作为tag
+func (r *RedisWrapper) SlotTag(key string) string {
+	if _, ok := hashTag(key); ok {
+		return key
+	}
+	return "{" + r.AddPrefix(key) + "}"
+}
+
+// 可以按slot拆分执行的多键命令，MSET拆分后不再是原子的
+var splitCommands = map[string]int{
+	"DEL": 1, "UNLINK": 1, "EXISTS": 1, "TOUCH": 1, "MGET": 1, "MSET": 2,
+}
+
+// RedisCluster 集群容器，每个主节点一个连接池，实现 RedisContainer
+// 事务使用第一个键所在节点的连接，所有键要在同一个slot；SCAN会遍历所有主节点
+// DEL MGET MSET等多键命令按slot拆分，其他跨slot的命令由服务端返回CROSSSLOT错误
+type RedisCluster struct {
+	seeds       []string
+	dialOpts    func() ([]redis.DialOption, error)
+	Dial        func(addr string, opts ...redis.DialOption) (redis.Conn, error) // 默认使用TCP连接
+	MaxIdle     int
+	IdleTimeout time.Duration
+	slots       [REDIS_CLUSTER_SLOTS]string
+	pools       map[string]*redis.Pool
+	refreshing  atomic.Bool
+	mu          sync.RWMutex
+}
+
+// NewRedisCluster 根据种子节点创建集群，首次使用时发现slot分布
+func NewRedisCluster(seeds []string, dialOpts func() ([]redis.DialOption, error)) *RedisCluster {
+	return &RedisCluster{
+		seeds: seeds, dialOpts: dialOpts, pools: make(map[string]*redis.Pool),
+		MaxIdle:     REDIS_DEFAULT_IDLE_CONN,
+		IdleTimeout: REDIS_DEFAULT_IDLE_TIMEOUT * time.Second,
+	}
+}
+
+// NewRedisClusterPool 根据 conn "redis" 中的 nodes 建立集群
+func NewRedisClusterPool(cfg dialect.ConnConfig, maxIdle int) *RedisWrapper {
+	r := NewRedisWrapper()
+	r.ConnKey = cfg.Key
+	if maxIdle >= 0 {
+		r.MaxIdleConn = maxIdle
+	}
+	var seeds []string
+	if dia, ok := cfg.LoadDialect().(*dialect.Redis); ok {
+		seeds = dia.Nodes
+	}
+	cluster := NewRedisCluster(seeds, func() ([]redis.DialOption, error) {
+		return RedisDialOptions(cfg, 0)
+	})
+	cluster.MaxIdle = r.MaxIdleConn
+	cluster.IdleTimeout = time.Second * time.Duration(r.MaxIdleTime)
+	r.RedisContainer = cluster
+	return r
+}
+
+// getPool 节点的连接池，不存在时创建
+func (c *RedisCluster) getPool(addr string) *redis.Pool {
+	c.mu.RLock()
+	pool, ok := c.pools[addr]
+	c.mu.RUnlock()
+	if ok {
+		return pool
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pool, ok = c.pools[addr]; !ok {
+		pool = &redis.Pool{
+			MaxIdle: c.MaxIdle, IdleTimeout: c.IdleTimeout,
+			Dial: func() (redis.Conn, error) {
+				var opts []redis.DialOption
+				if c.dialOpts != nil {
+					var err error
+					if opts, err = c.dialOpts(); err != nil {
+						return nil, err
+					}
+				}
+				if c.Dial != nil {
+					return c.Dial(addr, opts...)
+				}
+				return redis.Dial("tcp", addr, opts...)
+			},
+		}
+		c.pools[addr] = pool
+	}
+	return pool
+}
+
+// Nodes 已知的节点地址
+func (c *RedisCluster) Nodes() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	nodes := append([]string{}, c.seeds...)
+	for addr := range c.pools {
+		nodes = append(nodes, addr)
+	}
+	return nodes
+}
+
+// Masters 所有主节点的地址，slot分布未知时先刷新
+func (c *RedisCluster) Masters() ([]string, error) {
+	for i := 0; i < 2; i++ {
+		var addrs []string
+		c.mu.RLock()
+		for _, addr := range c.slots {
+			if addr != "" && !slices.Contains(addrs, addr) {
+				addrs = append(addrs, addr)
+			}
+		}
+		c.mu.RUnlock()
+		if len(addrs) > 0 {
+			slices.Sort(addrs)
+			return addrs, nil
+		}
+		if err := c.Refresh(); err != nil {
+			return nil, err
+		}
+	}
+	return nil, errors.New("there is no master in redis cluster")
+}
+
+// NodeConn 指定节点的连接，不会跟随MOVED跳转
+func (c *RedisCluster) NodeConn(addr string) redis.Conn {
+	return c.getPool(addr).Get()
+}
+
+// Refresh 用 CLUSTER SLOTS 重新获取slot分布
+func (c *RedisCluster) Refresh() error {
+	err := errors.New("there is no node in redis cluster")
+	for _, addr := range c.Nodes() {
+		conn := c.getPool(addr).Get()
+		var values []any
+		values, err = redis.Values(conn.Do("CLUSTER", "SLOTS"))
+		conn.Close()
+		if err == nil {
+			return c.updateSlots(values, addr)
+		}
+	}
+	return err
+}
+
+// updateSlots 解析 [[start, end, [ip, port, id], 从节点...], ...]
+// ip为空表示就是被询问的节点from，使用from的主机名
+func (c *RedisCluster) updateSlots(values []any, from string) error {
+	fromHost, _, _ := net.SplitHostPort(from)
+	var slots [REDIS_CLUSTER_SLOTS]string
+	for _, value := range values {
+		parts, err := redis.Values(value, nil)
+		if err != nil || len(parts) < 3 {
+			return fmt.Errorf("the reply of CLUSTER SLOTS is invalid")
+		}
+		start, _ := redis.Int(parts[0], nil)
+		end, _ := redis.Int(parts[1], nil)
+		node, err := redis.Values(parts[2], nil)
+		if err != nil || len(node) < 2 {
+			return fmt.Errorf("the reply of CLUSTER SLOTS is invalid")
+		}
+		host, _ := redis.String(node[0], nil)
+		port, _ := redis.Int(node[1], nil)
+		if host == "" {
+			host = fromHost
+		}
+		addr := net.JoinHostPort(host, strconv.Itoa(port))
+		for i := max(start, 0); i <= end && i < REDIS_CLUSTER_SLOTS; i++ {
+			slots[i] = addr
+		}
+	}
+	c.mu.Lock()
+	c.slots = slots
+	c.mu.Unlock()
+	return nil
+}
+
+// addrOfSlot slot所在的主节点，slot<0时随便选一个节点
+func (c *RedisCluster) addrOfSlot(slot int) (string, error) {
+	for i := 0; i < 2; i++ {
+		c.mu.RLock()
+		var addr string
+		if slot >= 0 {
+			addr = c.slots[slot]
+		} else if n := len(c.pools); n > 0 {
+			for a := range c.pools {
+				addr = a
+				break
+			}
+		}
+		c.mu.RUnlock()
+		if addr != "" {
+			return addr, nil
+		} else if slot < 0 && len(c.seeds) > 0 {
+			return c.seeds[rand.Intn(len(c.seeds))], nil
+		}
+		if err := c.Refresh(); err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("the slot %d is not served in redis cluster", slot)
+}
+
+// moved slot已经迁移，立即修正这个slot，再在后台刷新全部，同一时间只刷新一次
+func (c *RedisCluster) moved(slot int, addr string) {
+	if slot >= 0 && slot < REDIS_CLUSTER_SLOTS {
+		c.mu.Lock()
+		c.slots[slot] = addr
+		c.mu.Unlock()
+	}
+	if c.refreshing.CompareAndSwap(false, true) {
+		go func() {
+			defer c.refreshing.Store(false)
+			_ = c.Refresh()
+		}()
+	}
+}
+
+// SlotConn slot所在主节点的连接，用于事务等需要独占连接的场合，不会跟随MOVED跳转
+func (c *RedisCluster) SlotConn(slot int) (redis.Conn, error) {
+	addr, err := c.addrOfSlot(slot)
+	if err != nil {
+		return nil, err
+	}
+	return c.NodeConn(addr), nil
+}
+
+// checkMoved 独占连接上遇到MOVED时修正slot，下次重试会连到新的节点
+func (c *RedisCluster) checkMoved(err error) {
+	if kind, slot, addr := parseRedirect(err); kind == "MOVED" {
+		c.moved(slot, addr)
+	}
+}
+
+// Get 获得一个连接，实现 RedisContainer
+func (c *RedisCluster) Get() redis.Conn {
+	return &clusterConn{cluster: c}
+}
+
+// Close 关闭所有节点的连接池
+func (c *RedisCluster) Close() (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for addr, pool := range c.pools {
+		if e := pool.Close(); e != nil {
+			err = e
+		}
+		delete(c.pools, addr)
+	}
+	return
+}
+
+// parseRedirect 解析 MOVED 3999 127.0.0.1:6381 和 ASK 3999 127.0.0.1:6381
+func parseRedirect(err error) (kind string, slot int, addr string) {
+	var rerr redis.Error
+	if !errors.As(err, &rerr) {
+		return
+	}
+	fields := strings.Fields(string(rerr))
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return
+	}
+	slot, _ = strconv.Atoi(fields[1])
+	return fields[0], slot, fields[2]
+}
+
+// clusterConn 按照键把命令路由到对应节点，Send的命令在Flush时依次执行
+type clusterConn struct {
+	cluster *RedisCluster
+	queued  []pipeCmd
+	pending []pendingReply
+	closed  bool
+}
+
+func (cc *clusterConn) Close() error {
+	cc.closed, cc.queued, cc.pending = true, nil, nil
+	return nil
+}
+
+func (cc *clusterConn) Err() error {
+	if cc.closed {
+		return errors.New("redisw: the cluster connection is closed")
+	}
+	return nil
+}
+
+func (cc *clusterConn) Send(cmd string, args ...any) error {
+	if err := cc.Err(); err != nil {
+		return err
+	}
+	cc.queued = append(cc.queued, pipeCmd{name: cmd, args: args})
+	return nil
+}
+
+func (cc *clusterConn) Flush() error {
+	return cc.flush(0)
+}
+
+func (cc *clusterConn) flush(timeout time.Duration) error {
+	for _, c := range cc.queued {
+		reply, err := cc.route(timeout, c.name, c.args)
+		cc.pending = append(cc.pending, pendingReply{reply, err})
+	}
+	cc.queued = nil
+	return cc.Err()
+}
+
+func (cc *clusterConn) Receive() (any, error) {
+	return cc.ReceiveWithTimeout(0)
+}
+
+func (cc *clusterConn) ReceiveWithTimeout(timeout time.Duration) (any, error) {
+	_ = cc.flush(timeout)
+	if len(cc.pending) == 0 {
+		return nil, errors.New("redisw: no pending reply to receive")
+	}
+	r := cc.pending[0]
+	cc.pending = cc.pending[1:]
+	return r.reply, r.err
+}
+
+func (cc *clusterConn) Do(cmd string, args ...any) (any, error) {
+	return cc.DoWithTimeout(0, cmd, args...)
+}
+
+// DoWithTimeout 和redigo一样，先执行积攒的命令，返回第一个错误和最后的应答
+func (cc *clusterConn) DoWithTimeout(timeout time.Duration, cmd string, args ...any) (any, error) {
+	if err := cc.flush(timeout); err != nil {
+		return nil, err
+	}
+	var firstErr error
+	for _, r := range cc.pending {
+		if firstErr == nil && r.err != nil {
+			firstErr = r.err
+		}
+	}
+	cc.pending = nil
+	if cmd == "" {
+		return nil, firstErr
+	}
+	reply, err := cc.route(timeout, cmd, args)
+	if err == nil {
+		err = firstErr
+	}
+	return reply, err
+}
+
+// route 发给键所在的节点，可以拆分的多键命令按slot分别执行
+func (cc *clusterConn) route(timeout time.Duration, cmd string, args []any) (any, error) {
+	keys := CommandKeys(cmd, args)
+	if len(keys) == 0 {
+		return cc.routeSlot(timeout, -1, cmd, args)
+	}
+	name := strings.ToUpper(cmd)
+	width, ok := splitCommands[name]
+	if !ok || len(keys) == 1 {
+		return cc.routeSlot(timeout, KeySlot(FormatValue(args[keys[0]])), cmd, args)
+	}
+	var slots []int
+	groups := make(map[int][]int) // slot => 键在参数中的位置
+	for _, i := range keys {
+		slot := KeySlot(FormatValue(args[i]))
+		if _, ok = groups[slot]; !ok {
+			slots = append(slots, slot)
+		}
+		groups[slot] = append(groups[slot], i)
+	}
+	if len(slots) == 1 {
+		return cc.routeSlot(timeout, slots[0], cmd, args)
+	}
+	var total int64
+	values := make([]any, len(keys))
+	for _, slot := range slots {
+		sub := make([]any, 0, len(groups[slot])*width)
+		for _, i := range groups[slot] {
+			sub = append(sub, args[i:i+width]...)
+		}
+		reply, err := cc.routeSlot(timeout, slot, cmd, sub)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "MGET": // 键从第一个参数开始，位置就是应答中的下标
+			items, err := redis.Values(reply, nil)
+			if err != nil {
+				return nil, err
+			}
+			for j, i := range groups[slot] {
+				values[i] = items[j]
+			}
+		case "MSET":
+		default:
+			n, err := redis.Int64(reply, nil)
+			if err != nil {
+				return nil, err
+			}
+			total += n
+		}
+	}
+	switch name {
+	case "MGET":
+		return values, nil
+	case "MSET":
+		return "OK", nil
+	}
+	return total, nil
+}
+
+// routeSlot 发给slot所在的节点，遇到MOVED和ASK时跳转，slot<0时随便选一个节点
+func (cc *clusterConn) routeSlot(timeout time.Duration, slot int, cmd string, args []any) (any, error) {
+	addr, err := cc.cluster.addrOfSlot(slot)
+	if err != nil {
+		return nil, err
+	}
+	asking := false
+	for i := 0; i <= REDIS_CLUSTER_MAX_REDIRECTS; i++ {
+		conn := cc.cluster.getPool(addr).Get()
+		if asking {
+			_ = conn.Send("ASKING")
+		}
+		var reply any
+		if timeout > 0 {
+			reply, err = redis.DoWithTimeout(conn, timeout, cmd, args...)
+		} else {
+			reply, err = conn.Do(cmd, args...)
+		}
+		conn.Close()
+		kind, movedSlot, newAddr := parseRedirect(err)
+		switch kind {
+		case "MOVED":
+			cc.cluster.moved(movedSlot, newAddr)
+			addr, asking = newAddr, false
+		case "ASK":
+			addr, asking = newAddr, true
+		default:
+			return reply, err
+		}
+	}
+	return nil, TooManyRedirectsError
+}
@@ -0,0 +1,151 @@
+package redisw_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azhai/xgen/redisw"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterSlot(t *testing.T) {
+	assert.Equal(t, 12182, redisw.KeySlot("foo"))
+	assert.Equal(t, 12739, redisw.KeySlot("123456789"))
+	assert.Equal(t, redisw.KeySlot("user"), redisw.KeySlot("{user}:1000"))
+	assert.Equal(t, redisw.KeySlot("{}x"), redisw.KeySlot("{}x")) // 空的tag不生效
+
+	assert.Equal(t, []int{0}, redisw.CommandKeys("hget", []any{"h", "f"}))
+	assert.Equal(t, []int{0, 2}, redisw.CommandKeys("MSET", []any{"a", 1, "b", 2}))
+	assert.Equal(t, []int{0, 1}, redisw.CommandKeys("BLPOP", []any{"a", "b", 5}))
+	assert.Equal(t, []int{2, 3}, redisw.CommandKeys("EVAL", []any{"return 1", 2, "k1", "k2", "a1"}))
+	assert.Equal(t, []int{4, 5}, redisw.CommandKeys("XREADGROUP",
+		[]any{"GROUP", "g", "c", "STREAMS", "s1", "s2", ">", ">"}))
+	assert.Empty(t, redisw.CommandKeys("PING", nil))
+
+	r := redisw.NewRedisWrapper()
+	r.RedisContainer = redisw.NewRedisCluster(nil, nil)
+	assert.True(t, r.CanTx()) // 同一个slot的键可以使用事务
+	_, err := r.Exec("GET", "foo")
+	assert.Error(t, err)
+	_, err = r.Tx(func(p *redisw.Pipeline) error {
+		p.Send("INCR", "foo")
+		return nil
+	})
+	assert.Error(t, err)
+
+	mux := redisw.NewRedisConnMux(redisw.NewFakeContainer().Get(), nil)
+	assert.False(t, mux.CanTx())
+	_, err = mux.Tx(func(p *redisw.Pipeline) error {
+		p.Send("INCR", "foo")
+		return nil
+	})
+	assert.ErrorIs(t, err, redisw.TxUnsupportedError)
+}
+
+// slotsConn 替身节点，应答 CLUSTER SLOTS ，前一半slot在a节点，后一半在b节点
+// 和真实的集群一样，被询问的a节点不知道自己的ip，返回空的主机名
+type slotsConn struct {
+	redis.Conn
+}
+
+func (c slotsConn) Do(cmd string, args ...any) (any, error) {
+	if strings.EqualFold(cmd, "CLUSTER") {
+		return []any{
+			[]any{int64(0), int64(8191), []any{"", int64(1)}},
+			[]any{int64(8192), int64(16383), []any{"b", int64(2)}},
+		}, nil
+	}
+	return c.Conn.Do(cmd, args...)
+}
+
+func TestClusterRoute(t *testing.T) {
+	nodes := map[string]*redisw.FakeContainer{
+		"a:1": redisw.NewFakeContainer(), "b:2": redisw.NewFakeContainer(),
+	}
+	cluster := redisw.NewRedisCluster([]string{"a:1"}, nil)
+	cluster.Dial = func(addr string, _ ...redis.DialOption) (redis.Conn, error) {
+		return slotsConn{nodes[addr].Get()}, nil
+	}
+	r := redisw.NewRedisWrapper()
+	r.MaxReadTime, r.RedisContainer = 0, cluster
+	r = r.WithPrefix("app:")
+	masters, err := cluster.Masters()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a:1", "b:2"}, masters)
+
+	keys := []string{"k1", "k2", "k3", "k4", "k5", "k6"}
+	args := make([]any, 0, len(keys)*2)
+	for _, key := range keys {
+		args = append(args, key, "v-"+key)
+	}
+	_, err = r.Exec("MSET", args...)
+	assert.NoError(t, err)
+	counts := make(map[string]int)
+	for _, key := range keys { // 每个键只保存在所属slot的节点上
+		addr := "a:1"
+		if redisw.KeySlot("app:"+key) > 8191 {
+			addr = "b:2"
+		}
+		counts[addr]++
+		val, _ := redis.String(nodes[addr].Get().Do("GET", "app:"+key))
+		assert.Equal(t, "v-"+key, val)
+	}
+	assert.Len(t, counts, 2)
+	vals, err := redis.Strings(r.Exec("MGET", "k3", "k1", "k6"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"v-k3", "v-k1", "v-k6"}, vals)
+
+	found, err := r.Scan(redisw.ScanOptions{Match: "k*"}).Collect()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, keys, found)
+	n, err := redis.Int(r.Exec("DEL", "k1", "k2"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	n, err = r.DeleteMatching("k*", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	// 临时消息和锁的fence与主键在同一个slot
+	assert.Equal(t, redisw.KeySlot("app:sess:x"), redisw.KeySlot("app:"+r.GetFlashKey("sess:x")))
+	mutex := redisw.NewMutex(r, "lock", time.Second)
+	assert.Equal(t, redisw.KeySlot("app:lock"), redisw.KeySlot("app:"+mutex.GetFenceKey()))
+
+	// 会话和用户集合分布在不同节点，不使用事务
+	reg := redisw.NewRegistry(r)
+	tokens := []string{"t1", "t2", "t3", "t4"}
+	for _, token := range tokens {
+		_, err = reg.GetSession(token, 60).BindUser("u1", nil, false)
+		assert.NoError(t, err)
+	}
+	found, _ = reg.ListSessions("u1")
+	assert.ElementsMatch(t, tokens, found)
+	kicked, err := reg.KickOthers("u1", "t1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"t2", "t3", "t4"}, kicked)
+	kicked, err = reg.GetSession("t5", 60).BindUser("u1", nil, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"t1"}, kicked)
+	found, _ = reg.ListSessions("u1")
+	assert.Equal(t, []string{"t5"}, found)
+
+	// 新旧token在不同节点，复制字段、临时消息和过期时间
+	assert.NotEqual(t, redisw.KeySlot("app:sess:t5") > 8191, redisw.KeySlot("app:sess:t6") > 8191)
+	t5 := reg.GetSession("t5", 60)
+	t5.SetVal("name", "bob")
+	t5.AddFlash("hi")
+	t6, err := reg.RotateSession("t5", "t6", 60)
+	assert.NoError(t, err)
+	name, _ := t6.GetString("name")
+	assert.Equal(t, "bob", name)
+	token, _ := t6.GetString(redisw.SESS_TOKEN_KEY)
+	assert.Equal(t, "t6", token)
+	assert.Equal(t, 60, r.GetTimeout("sess:t6"))
+	flashes, _ := t6.GetFlashes(-1)
+	assert.Equal(t, []string{"hi"}, flashes)
+	found, _ = reg.ListSessions("u1")
+	assert.Equal(t, []string{"t6"}, found)
+	n, _ = redis.Int(r.Exec("EXISTS", "sess:t5"))
+	assert.Equal(t, 0, n)
+}
@@ -0,0 +1,255 @@
+package redisw
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/shamaton/msgpack/v2"
+)
+
+const (
+	CODEC_HEADER              byte = 0xc1 // 压缩数据的首字节，JSON、gob和MessagePack都不会以它开头
+	COMPRESS_NONE             byte = 0
+	COMPRESS_GZIP             byte = 1
+	COMPRESS_ZSTD             byte = 2
+	CODEC_DEFAULT_MIN_SIZE         = 1024     // 编码后超过这个长度才压缩
+	CODEC_MAX_DECOMPRESS_SIZE      = 64 << 20 // 解压后的最大长度，防止压缩炸弹
+)
+
+// DecompressTooLargeError 解压后超过 CODEC_MAX_DECOMPRESS_SIZE
+var DecompressTooLargeError = errors.New("the decompressed data is too large")
+
+var (
+	compressors = map[byte]Compressor{COMPRESS_GZIP: gzipCompressor{}, COMPRESS_ZSTD: &zstdCompressor{}}
+	compressMu  sync.RWMutex
+
+	JsonCodec    Codec = jsonCodec{}
+	GobCodec     Codec = gobCodec{}
+	MsgpackCodec Codec = msgpackCodec{}
+
+	DefaultSerializer = &Serializer{Codec: JsonCodec}
+)
+
+// Codec 值的编码方式
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// gobCodec 比JSON紧凑，但只能在Go程序之间使用
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// msgpackCodec MessagePack格式，字段名使用msgpack标签，没有标签时使用字段名
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+// Compressor 压缩算法
+type Compressor interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// RegisterCompressor 注册压缩算法，也可以替换内置的gzip和zstd
+func RegisterCompressor(id byte, c Compressor) {
+	compressMu.Lock()
+	defer compressMu.Unlock()
+	compressors[id] = c
+}
+
+func getCompressor(id byte) (Compressor, error) {
+	compressMu.RLock()
+	defer compressMu.RUnlock()
+	if c, ok := compressors[id]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("redisw: the compressor %d is not registered", id)
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	err := w.Close()
+	return buf.Bytes(), err
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err = io.ReadAll(io.LimitReader(r, CODEC_MAX_DECOMPRESS_SIZE+1))
+	if err == nil && len(data) > CODEC_MAX_DECOMPRESS_SIZE {
+		return nil, DecompressTooLargeError
+	}
+	return data, err
+}
+
+// zstdCompressor 比gzip快，编码器和解码器第一次使用时创建，可以并发使用
+type zstdCompressor struct {
+	once sync.Once
+	enc  *zstd.Encoder
+	dec  *zstd.Decoder
+	err  error
+}
+
+func (z *zstdCompressor) init() error {
+	z.once.Do(func() {
+		if z.enc, z.err = zstd.NewWriter(nil); z.err == nil {
+			z.dec, z.err = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(CODEC_MAX_DECOMPRESS_SIZE))
+		}
+	})
+	return z.err
+}
+
+func (z *zstdCompressor) Compress(data []byte) ([]byte, error) {
+	if err := z.init(); err != nil {
+		return nil, err
+	}
+	return z.enc.EncodeAll(data, nil), nil
+}
+
+func (z *zstdCompressor) Decompress(data []byte) ([]byte, error) {
+	if err := z.init(); err != nil {
+		return nil, err
+	}
+	data, err := z.dec.DecodeAll(data, nil)
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		return nil, DecompressTooLargeError
+	}
+	return data, err
+}
+
+// Serializer 编码加上可选的压缩，压缩后的数据为 CODEC_HEADER + 算法 + 压缩内容
+type Serializer struct {
+	Codec    Codec
+	Compress byte // 压缩算法，COMPRESS_NONE 不压缩
+	MinSize  int  // 编码后达到这个长度才压缩，0使用 CODEC_DEFAULT_MIN_SIZE
+}
+
+// NewSerializer 使用指定的编码和压缩
+func NewSerializer(codec Codec, compress byte) *Serializer {
+	return &Serializer{Codec: codec, Compress: compress, MinSize: CODEC_DEFAULT_MIN_SIZE}
+}
+
+func (s *Serializer) Encode(v any) ([]byte, error) {
+	data, err := s.Codec.Marshal(v)
+	if err != nil || s.Compress == COMPRESS_NONE {
+		return data, err
+	}
+	minSize := s.MinSize
+	if minSize <= 0 {
+		minSize = CODEC_DEFAULT_MIN_SIZE
+	}
+	if len(data) < minSize {
+		return data, nil
+	}
+	c, err := getCompressor(s.Compress)
+	if err != nil {
+		return nil, err
+	}
+	packed, err := c.Compress(data)
+	if err != nil || len(packed)+2 >= len(data) { // 压缩没有效果时保存原文
+		return data, err
+	}
+	return append([]byte{CODEC_HEADER, s.Compress}, packed...), nil
+}
+
+// Decode 有压缩标记时先解压，因此可以读取开启压缩之前保存的值
+func (s *Serializer) Decode(data []byte, v any) error {
+	if len(data) >= 2 && data[0] == CODEC_HEADER {
+		c, err := getCompressor(data[1])
+		if err != nil {
+			return err
+		}
+		if data, err = c.Decompress(data[2:]); err != nil {
+			return err
+		}
+	}
+	return s.Codec.Unmarshal(data, v)
+}
+
+// GetSerializer 包装器使用的编码，默认是不压缩的JSON
+func (r *RedisWrapper) GetSerializer() *Serializer {
+	if r.Serializer != nil {
+		return r.Serializer
+	}
+	return DefaultSerializer
+}
+
+// WithSerializer 复制一个使用其他编码的包装器，共用同一个容器，可用于单次调用
+func (r *RedisWrapper) WithSerializer(s *Serializer) *RedisWrapper {
+	dup := *r
+	dup.Serializer = s
+	return &dup
+}
+
+// SaveValue 按照包装器的编码保存对象
+func (r *RedisWrapper) SaveValue(key string, obj any, timeout int) (bool, error) {
+	value, err := r.GetSerializer().Encode(obj)
+	if err != nil {
+		return false, err
+	}
+	return r.SetVal(key, value, timeout)
+}
+
+// LoadValue 读取 SaveValue 保存的对象
+func (r *RedisWrapper) LoadValue(key string, obj any) error {
+	value, err := r.GetBytes(key)
+	if err != nil {
+		return err
+	}
+	return r.GetSerializer().Decode(value, obj)
+}
+
+// EncodeArgs 和 Map2Args 一样，但是按照包装器的编码
+func (r *RedisWrapper) EncodeArgs(data Map) ([]any, error) {
+	s, args := r.GetSerializer(), make([]any, 0, len(data)*2)
+	for key, val := range data {
+		value, err := s.Encode(val)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, key, value)
+	}
+	return args, nil
+}
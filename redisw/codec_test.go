@@ -0,0 +1,99 @@
+package redisw_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/azhai/xgen/redisw"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeCodec(t *testing.T) {
+	type item struct {
+		Id    int64             `json:"id"`
+		Name  string            `json:"name"`
+		Price float64           `json:"price"`
+		Big   uint64            `json:"big"`
+		Tags  []string          `json:"tags"`
+		Attrs map[string]string `json:"attrs"`
+		Data  []byte            `json:"data"`
+		Note  *string           `json:"note"`
+	}
+	obj := item{Id: -70000, Name: strings.Repeat("x", 2000), Price: 1.5, Big: 1 << 63,
+		Tags: []string{"a", "b"}, Attrs: map[string]string{"k": "v"}, Data: []byte{0, 1, 2}}
+	for _, codec := range []redisw.Codec{redisw.JsonCodec, redisw.GobCodec, redisw.MsgpackCodec} {
+		for _, compress := range []byte{redisw.COMPRESS_NONE, redisw.COMPRESS_GZIP, redisw.COMPRESS_ZSTD} {
+			s := redisw.NewSerializer(codec, compress)
+			s.MinSize = 64
+			data, err := s.Encode(obj)
+			assert.NoError(t, err)
+			assert.Equal(t, compress != redisw.COMPRESS_NONE, data[0] == redisw.CODEC_HEADER)
+			var got item
+			assert.NoError(t, s.Decode(data, &got))
+			assert.Equal(t, obj, got)
+		}
+	}
+	data, err := redisw.MsgpackCodec.Marshal([]any{1, true, nil, []byte{0xff}})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x94, 0x01, 0xc3, 0xc0, 0xc4, 0x01, 0xff}, data) // 二进制使用bin类型
+	_, err = redisw.NewSerializer(redisw.JsonCodec, 9).Encode(obj)
+	assert.Error(t, err)
+	zs, err := redisw.CacheSerializer("msgpack", "zstd")
+	assert.NoError(t, err)
+	zs.MinSize = 64
+	data, err = zs.Encode(obj)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{redisw.CODEC_HEADER, redisw.COMPRESS_ZSTD}, data[:2])
+	_, err = redisw.CacheSerializer("gob", "lz4")
+	assert.Error(t, err)
+
+	r := redisw.NewRedisFake()
+	r.SaveJson("test:a", obj, 60) // 开启压缩之前保存的值
+	gz := r.WithSerializer(redisw.NewSerializer(redisw.JsonCodec, redisw.COMPRESS_GZIP))
+	assert.Nil(t, r.Serializer)
+	var got item
+	assert.NoError(t, gz.LoadValue("test:a", &got))
+	assert.Equal(t, obj, got)
+	gz.SaveValue("test:b", obj, 60)
+	raw, err := r.GetBytes("test:b")
+	assert.NoError(t, err)
+	assert.Equal(t, redisw.CODEC_HEADER, raw[0])
+	assert.NoError(t, r.LoadJson("test:b", &got)) // 解码时识别压缩标记
+	assert.Equal(t, obj, got)
+
+	// SaveJson SaveMap 和关联数据也使用包装器的编码
+	mp := r.WithSerializer(redisw.NewSerializer(redisw.MsgpackCodec, redisw.COMPRESS_NONE))
+	mp.SaveJson("test:c", map[string]int{"n": 1}, 60)
+	raw, _ = r.GetBytes("test:c")
+	assert.Equal(t, []byte{0x81, 0xa1, 'n', 0x01}, raw)
+	mp.SaveMap(redisw.Map{"test:d": "x"}, true)
+	raw, _ = r.GetBytes("test:d")
+	assert.Equal(t, []byte{0xa1, 'x'}, raw)
+	rh := redisw.NewRedisHash(mp, "profile:7", 60)
+	_, err = rh.SaveForeignData(redisw.Map{"addr": Address{ID: 8, City: "Rome"}})
+	assert.NoError(t, err)
+	addr := &Address{}
+	assert.NoError(t, rh.LoadForeignJson(redisw.Map{"addr": addr}))
+	assert.Equal(t, "Rome", addr.City)
+	raw, _ = r.GetBytes("addr:8")
+	assert.NotEqual(t, byte('{'), raw[0])
+
+	// 解压后的长度有上限
+	zeros := make([]byte, 1<<20)
+	var gzBuf, zsBuf bytes.Buffer
+	gw := gzip.NewWriter(&gzBuf)
+	zw, _ := zstd.NewWriter(&zsBuf)
+	for i := 0; i <= redisw.CODEC_MAX_DECOMPRESS_SIZE>>20; i++ {
+		gw.Write(zeros)
+		zw.Write(zeros)
+	}
+	gw.Close()
+	zw.Close()
+	for compress, packed := range map[byte][]byte{redisw.COMPRESS_GZIP: gzBuf.Bytes(), redisw.COMPRESS_ZSTD: zsBuf.Bytes()} {
+		bomb := append([]byte{redisw.CODEC_HEADER, compress}, packed...)
+		assert.ErrorIs(t, redisw.DefaultSerializer.Decode(bomb, &got), redisw.DecompressTooLargeError)
+	}
+}
@@ -1,6 +1,7 @@
 package redisw
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -40,10 +41,13 @@ type RedisContainer interface {
 
 // RedisWrapper Redis包装器，给容器加上超时等参数
 type RedisWrapper struct {
-	MaxIdleConn int // 最大空闲连接数
-	MaxIdleTime int // 最大空闲时长
-	RetryTimes  int // 重试次数
-	MaxReadTime int // 命令最大执行时长（不算连接部分）
+	MaxIdleConn int         // 最大空闲连接数
+	MaxIdleTime int         // 最大空闲时长
+	RetryTimes  int         // 重试次数
+	MaxReadTime int         // 命令最大执行时长（不算连接部分）
+	ConnKey     string      // 连接名，用于命令钩子
+	Serializer  *Serializer // 对象的编码和压缩，nil时使用JSON
+	Prefix      string      // 键的前缀，自动加到命令中的键上，发布订阅的频道不加
 	RedisContainer
 }
 
@@ -70,22 +74,60 @@ func NewRedisConn(cfg dialect.ConnConfig) (redis.Conn, error) {
 	return NewRedisConnDb(cfg, -1)
 }
 
-// NewRedisConnDb 建立Redis实际连接
+// NewRedisConnDb 建立Redis实际连接，哨兵模式下连接当前的主库
 func NewRedisConnDb(cfg dialect.ConnConfig, db int) (redis.Conn, error) {
+	return NewRedisConnContext(context.Background(), cfg, db)
+}
+
+// NewRedisConnContext 和 NewRedisConnDb 一样，单机模式下连接时遵守ctx的截止时间
+func NewRedisConnContext(ctx context.Context, cfg dialect.ConnConfig, db int) (redis.Conn, error) {
+	opts, err := RedisDialOptions(cfg, db)
+	if err != nil {
+		return nil, err
+	}
+	if dia, ok := cfg.LoadDialect().(*dialect.Redis); ok {
+		switch dia.GetMode() {
+		case dialect.REDIS_MODE_SENTINEL:
+			return NewSentinel(cfg).DialMaster(opts...)
+		case dialect.REDIS_MODE_CLUSTER:
+			return nil, fmt.Errorf("the conn %s is a redis cluster, use NewRedisPool instead", cfg.Key)
+		}
+	}
+	dsn, err := cfg.ResolveDSN(false)
+	if err != nil {
+		return nil, err
+	}
+	return redis.DialURLContext(ctx, dsn, opts...)
+}
+
+// RedisDialOptions 账号和数据库参数，db<0时使用配置中的数据库
+func RedisDialOptions(cfg dialect.ConnConfig, db int) ([]redis.DialOption, error) {
 	var opts []redis.DialOption
 	if cfg.Username != "" {
 		opts = append(opts, redis.DialUsername(cfg.Username))
 	}
 	if cfg.Password != "" {
-		opts = append(opts, redis.DialPassword(cfg.Password))
+		password, err := cfg.GetPassword()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, redis.DialPassword(password))
 	}
-	if db >= 0 {
+	if dia, ok := cfg.LoadDialect().(*dialect.Redis); ok {
+		if db < 0 {
+			db = dia.Database
+		}
+		if dia.TLS { // 集群和哨兵模式的节点也使用TLS
+			opts = append(opts, redis.DialUseTLS(true))
+		}
+	}
+	if db > 0 {
 		opts = append(opts, redis.DialDatabase(db))
 	}
-	return redis.DialURL(cfg.GetDSN(false), opts...)
+	return opts, nil
 }
 
-// NewRedisConnMux 建立Redis连接复用
+// NewRedisConnMux 建立Redis连接复用，不能独占连接，不支持事务和阻塞命令
 func NewRedisConnMux(conn redis.Conn, err error) *RedisWrapper {
 	r := NewRedisWrapper()
 	r.MaxReadTime = 0 // 不支持 ConnWithTimeout 和 DoWithTimeout
@@ -95,9 +137,33 @@ func NewRedisConnMux(conn redis.Conn, err error) *RedisWrapper {
 	return r
 }
 
-// NewRedisPool 建立Redis连接池
+// NewRedisPool 建立Redis连接池，按照配置的模式连接单机、哨兵或集群
 func NewRedisPool(cfg dialect.ConnConfig, maxIdle int) *RedisWrapper {
+	return NewRedisPoolDb(cfg, -1, maxIdle)
+}
+
+// NewRedisPoolDb 和 NewRedisPool 一样，单机模式下db>=0时使用这个数据库
+func NewRedisPoolDb(cfg dialect.ConnConfig, db, maxIdle int) *RedisWrapper {
+	if dia, ok := cfg.LoadDialect().(*dialect.Redis); ok {
+		var r *RedisWrapper
+		switch dia.GetMode() {
+		case dialect.REDIS_MODE_SENTINEL:
+			r = NewRedisSentinelPool(cfg, maxIdle)
+		case dialect.REDIS_MODE_CLUSTER:
+			r = NewRedisClusterPool(cfg, maxIdle)
+		default:
+			r = newRedisPool(cfg, db, maxIdle)
+		}
+		r.Prefix = dia.Prefix
+		return r
+	}
+	return newRedisPool(cfg, db, maxIdle)
+}
+
+// newRedisPool 单机的连接池
+func newRedisPool(cfg dialect.ConnConfig, db, maxIdle int) *RedisWrapper {
 	r := NewRedisWrapper()
+	r.ConnKey = cfg.Key
 	if maxIdle >= 0 {
 		r.MaxIdleConn = maxIdle
 	}
@@ -105,7 +171,10 @@ func NewRedisPool(cfg dialect.ConnConfig, maxIdle int) *RedisWrapper {
 	r.RedisContainer = &redis.Pool{
 		MaxIdle: r.MaxIdleConn, IdleTimeout: timeout,
 		Dial: func() (redis.Conn, error) {
-			return NewRedisConn(cfg)
+			return NewRedisConnDb(cfg, db)
+		},
+		DialContext: func(ctx context.Context) (redis.Conn, error) {
+			return NewRedisConnContext(ctx, cfg, db)
 		},
 	}
 	return r
@@ -119,24 +188,19 @@ func (r *RedisWrapper) GetMaxReadDuration() time.Duration {
 	return 0
 }
 
-// Exec 执行命令，将会重试几次
+// Exec 执行命令，网络错误时重试几次，见 ExecContext
 func (r *RedisWrapper) Exec(cmd string, args ...any) (any, error) {
-	var (
-		err   error
-		reply any
-	)
-	mrd := r.GetMaxReadDuration()
-	for i := 0; i < r.RetryTimes; i++ {
-		if mrd > 0 {
-			reply, err = redis.DoWithTimeout(r.Get(), mrd, cmd, args...)
-		} else {
-			reply, err = r.Get().Do(cmd, args...)
-		}
-		if err == nil {
-			break
-		}
+	return r.ExecContext(context.Background(), cmd, args...)
+}
+
+// Eval 执行Lua脚本，优先使用EVALSHA
+func (r *RedisWrapper) Eval(script *redis.Script, keysAndArgs ...any) (any, error) {
+	conn := r.Get()
+	defer conn.Close()
+	if r.Prefix != "" {
+		conn = prefixConn{Conn: conn, r: r}
 	}
-	return reply, err
+	return script.Do(conn, keysAndArgs...)
 }
 
 // GetSize 当前db缓存键的数量
@@ -181,9 +245,9 @@ func (r *RedisWrapper) Exists(key string) (bool, error) {
 	return ReplyBool(r.Exec("EXISTS", key))
 }
 
-// Find 模糊查找
+// Find 模糊查找，使用SCAN而不是KEYS
 func (r *RedisWrapper) Find(wildcard string) ([]string, error) {
-	return redis.Strings(r.Exec("KEYS", wildcard))
+	return r.Scan(ScanOptions{Match: wildcard}).Collect()
 }
 
 func (r *RedisWrapper) Rename(old, dst string) (bool, error) {
@@ -0,0 +1,1225 @@
+package redisw
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+var (
+	errFakeWrongType = redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+	errFakeNotInt    = redis.Error("ERR value is not an integer or out of range")
+	errFakeNotFloat  = redis.Error("ERR value is not a valid float")
+	errFakeSyntax    = redis.Error("ERR syntax error")
+	errFakeNoKey     = redis.Error("ERR no such key")
+	errFakeClosed    = errors.New("redisw: the fake connection is closed")
+)
+
+type fakeEntry struct {
+	kind     string // string hash list set zset
+	str      []byte
+	hash     map[string][]byte
+	list     [][]byte
+	set      map[string]struct{}
+	zset     map[string]float64
+	expireAt time.Time // 零值表示无限
+}
+
+// FakeContainer 进程内的Redis替身，用于单元测试，只支持常用命令，不支持Lua脚本
+type FakeContainer struct {
+	data     map[string]*fakeEntry
+	versions map[string]int64 // 键的修改次数，用于WATCH
+	now      time.Time        // 零值表示使用系统时间
+	mu       sync.Mutex
+}
+
+// NewFakeContainer 创建空的Redis替身
+func NewFakeContainer() *FakeContainer {
+	return &FakeContainer{data: make(map[string]*fakeEntry), versions: make(map[string]int64)}
+}
+
+// NewRedisFake 包装一个Redis替身，可以替代 NewRedisPool 用于测试
+func NewRedisFake() *RedisWrapper {
+	r := NewRedisWrapper()
+	r.MaxReadTime, r.ConnKey = 0, "fake"
+	r.RedisContainer = NewFakeContainer()
+	return r
+}
+
+// Now 替身的当前时间
+func (c *FakeContainer) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.getNow()
+}
+
+// SetNow 固定替身的时钟，零值恢复使用系统时间
+func (c *FakeContainer) SetNow(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance 将替身的时钟拨快，用于测试过期
+func (c *FakeContainer) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.getNow().Add(d)
+}
+
+func (c *FakeContainer) getNow() time.Time {
+	if c.now.IsZero() {
+		return time.Now()
+	}
+	return c.now
+}
+
+// Get 获得一个连接，实现 RedisContainer
+func (c *FakeContainer) Get() redis.Conn {
+	return &fakeConn{container: c}
+}
+
+func (c *FakeContainer) Close() error {
+	return nil
+}
+
+// //////////////////////////////////////////////////////////
+// / 连接                                                   ///
+// //////////////////////////////////////////////////////////
+
+type pendingReply struct {
+	reply any
+	err   error
+}
+
+type fakeConn struct {
+	container *FakeContainer
+	pending   []pendingReply
+	queued    [][]string // MULTI之后积攒的命令
+	inMulti   bool
+	watched   map[string]int64
+	closed    bool
+}
+
+func (fc *fakeConn) Close() error {
+	fc.closed = true
+	fc.pending, fc.queued, fc.watched = nil, nil, nil
+	return nil
+}
+
+func (fc *fakeConn) Err() error {
+	if fc.closed {
+		return errFakeClosed
+	}
+	return nil
+}
+
+func (fc *fakeConn) Send(cmd string, args ...any) error {
+	if fc.closed {
+		return errFakeClosed
+	}
+	reply, err := fc.exec(cmd, args)
+	fc.pending = append(fc.pending, pendingReply{reply, err})
+	return nil
+}
+
+func (fc *fakeConn) Flush() error {
+	return fc.Err()
+}
+
+func (fc *fakeConn) Receive() (any, error) {
+	if fc.closed {
+		return nil, errFakeClosed
+	} else if len(fc.pending) == 0 {
+		return nil, errors.New("redisw: no pending reply to receive")
+	}
+	r := fc.pending[0]
+	fc.pending = fc.pending[1:]
+	return r.reply, r.err
+}
+
+// Do 和redigo一样，先读取积攒的应答，返回第一个错误和最后的应答
+func (fc *fakeConn) Do(cmd string, args ...any) (any, error) {
+	if fc.closed {
+		return nil, errFakeClosed
+	}
+	var firstErr error
+	for _, r := range fc.pending {
+		if firstErr == nil && r.err != nil {
+			firstErr = r.err
+		}
+	}
+	fc.pending = nil
+	if cmd == "" {
+		return nil, firstErr
+	}
+	reply, err := fc.exec(cmd, args)
+	if err == nil {
+		err = firstErr
+	}
+	return reply, err
+}
+
+func (fc *fakeConn) exec(cmd string, args []any) (any, error) {
+	name, strs := strings.ToUpper(cmd), fakeArgs(args)
+	c := fc.container
+	switch name {
+	case "MULTI":
+		if fc.inMulti {
+			return nil, redis.Error("ERR MULTI calls can not be nested")
+		}
+		fc.inMulti, fc.queued = true, nil
+		return "OK", nil
+	case "DISCARD":
+		fc.inMulti, fc.queued, fc.watched = false, nil, nil
+		return "OK", nil
+	case "EXEC":
+		if !fc.inMulti {
+			return nil, redis.Error("ERR EXEC without MULTI")
+		}
+		queued, watched := fc.queued, fc.watched
+		fc.inMulti, fc.queued, fc.watched = false, nil, nil
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for key, ver := range watched {
+			if c.versions[key] != ver {
+				return nil, nil // 被修改过，事务取消
+			}
+		}
+		replies := make([]any, len(queued))
+		for i, q := range queued {
+			reply, err := c.do(q[0], q[1:])
+			if err != nil {
+				reply = err
+			}
+			replies[i] = reply
+		}
+		return replies, nil
+	case "WATCH":
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if fc.watched == nil {
+			fc.watched = make(map[string]int64)
+		}
+		for _, key := range strs {
+			c.expireIfNeeded(key)
+			fc.watched[key] = c.versions[key]
+		}
+		return "OK", nil
+	case "UNWATCH":
+		fc.watched = nil
+		return "OK", nil
+	}
+	if fc.inMulti {
+		fc.queued = append(fc.queued, append([]string{name}, strs...))
+		return "QUEUED", nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.do(name, strs)
+}
+
+// fakeArgs 和redigo一样将参数转为字符串
+func fakeArgs(args []any) []string {
+	result := make([]string, 0, len(args))
+	for _, arg := range args {
+		if a, ok := arg.(redis.Argument); ok {
+			arg = a.RedisArg()
+		}
+		result = append(result, FormatValue(arg))
+	}
+	return result
+}
+
+// //////////////////////////////////////////////////////////
+// / 命令                                                   ///
+// //////////////////////////////////////////////////////////
+
+func fakeArity(args []string, n int, name string) error {
+	if len(args) < n {
+		return redis.Error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+	}
+	return nil
+}
+
+func fakeBulks(values []string) []any {
+	result := make([]any, len(values))
+	for i, v := range values {
+		result[i] = []byte(v)
+	}
+	return result
+}
+
+func fakeBool(ok bool) int64 {
+	if ok {
+		return 1
+	}
+	return 0
+}
+
+// fakeRange 将LRANGE等命令的下标转为切片范围
+func fakeRange(start, stop, size int) (int, int) {
+	if start < 0 {
+		start += size
+	}
+	if stop < 0 {
+		stop += size
+	}
+	start = max(start, 0)
+	stop = min(stop, size-1)
+	if start > stop {
+		return 0, 0
+	}
+	return start, stop + 1
+}
+
+// touch 记录键被修改，调用者需要持有mu
+func (c *FakeContainer) touch(key string) {
+	c.versions[key]++
+}
+
+func (c *FakeContainer) expireIfNeeded(key string) {
+	if e, ok := c.data[key]; ok && !e.expireAt.IsZero() && !c.getNow().Before(e.expireAt) {
+		delete(c.data, key)
+		c.touch(key)
+	}
+}
+
+// lookup 读取未过期的键，kind不符时返回WRONGTYPE
+func (c *FakeContainer) lookup(key, kind string) (*fakeEntry, error) {
+	c.expireIfNeeded(key)
+	e, ok := c.data[key]
+	if !ok {
+		return nil, nil
+	} else if kind != "" && e.kind != kind {
+		return nil, errFakeWrongType
+	}
+	return e, nil
+}
+
+// lookupOrCreate 读取键，不存在时创建
+func (c *FakeContainer) lookupOrCreate(key, kind string) (*fakeEntry, error) {
+	e, err := c.lookup(key, kind)
+	if err != nil || e != nil {
+		return e, err
+	}
+	e = &fakeEntry{kind: kind}
+	switch kind {
+	case "hash":
+		e.hash = make(map[string][]byte)
+	case "set":
+		e.set = make(map[string]struct{})
+	case "zset":
+		e.zset = make(map[string]float64)
+	}
+	c.data[key] = e
+	return e, nil
+}
+
+// setAlgebra 集合的交集、并集和差集，不存在的键视为空集合
+func (c *FakeContainer) setAlgebra(name string, keys []string) ([]string, error) {
+	sets := make([]map[string]struct{}, len(keys))
+	for i, key := range keys {
+		e, err := c.lookup(key, "set")
+		if err != nil {
+			return nil, err
+		} else if e != nil {
+			sets[i] = e.set
+		}
+	}
+	var members []string
+	if name == "SUNION" {
+		union := make(map[string]struct{})
+		for _, set := range sets {
+			for member := range set {
+				union[member] = struct{}{}
+			}
+		}
+		for member := range union {
+			members = append(members, member)
+		}
+	} else { // SINTER和SDIFF以第一个集合为准
+		for member := range sets[0] {
+			found := 0
+			for _, other := range sets[1:] {
+				if _, ok := other[member]; ok {
+					found++
+				}
+			}
+			if (name == "SINTER" && found == len(sets)-1) || (name == "SDIFF" && found == 0) {
+				members = append(members, member)
+			}
+		}
+	}
+	slices.Sort(members)
+	return members, nil
+}
+
+// fakeSortZSet 按照分数排序，分数相同时按照成员排序
+func fakeSortZSet(zset map[string]float64, reverse bool) []ZMember {
+	members := make([]ZMember, 0, len(zset))
+	for member, score := range zset {
+		members = append(members, ZMember{Member: member, Score: score})
+	}
+	slices.SortFunc(members, func(a, b ZMember) int {
+		if a.Score != b.Score {
+			if a.Score < b.Score {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Member, b.Member)
+	})
+	if reverse {
+		slices.Reverse(members)
+	}
+	return members
+}
+
+// removeIfEmpty 容器类型的键没有元素时删除
+func (c *FakeContainer) removeIfEmpty(key string, e *fakeEntry) {
+	if (e.kind == "hash" && len(e.hash) == 0) || (e.kind == "list" && len(e.list) == 0) ||
+		(e.kind == "set" && len(e.set) == 0) || (e.kind == "zset" && len(e.zset) == 0) {
+		delete(c.data, key)
+	}
+}
+
+func (c *FakeContainer) setString(key string, value []byte, expireAt time.Time) {
+	c.data[key] = &fakeEntry{kind: "string", str: value, expireAt: expireAt}
+	c.touch(key)
+}
+
+func (c *FakeContainer) incrBy(key string, delta int64) (any, error) {
+	e, err := c.lookup(key, "string")
+	if err != nil {
+		return nil, err
+	}
+	var num int64
+	if e != nil {
+		if num, err = strconv.ParseInt(string(e.str), 10, 64); err != nil {
+			return nil, errFakeNotInt
+		}
+	} else {
+		e = &fakeEntry{kind: "string"}
+		c.data[key] = e
+	}
+	num += delta
+	e.str = []byte(strconv.FormatInt(num, 10))
+	c.touch(key)
+	return num, nil
+}
+
+func (c *FakeContainer) expireAt(key string, at time.Time) int64 {
+	e, _ := c.lookup(key, "")
+	if e == nil {
+		return 0
+	}
+	if !at.After(c.getNow()) {
+		delete(c.data, key)
+	} else {
+		e.expireAt = at
+	}
+	c.touch(key)
+	return 1
+}
+
+func (c *FakeContainer) ttl(key string, unit time.Duration) int64 {
+	e, _ := c.lookup(key, "")
+	if e == nil {
+		return -2
+	} else if e.expireAt.IsZero() {
+		return -1
+	}
+	left := e.expireAt.Sub(c.getNow())
+	return int64((left + unit/2) / unit)
+}
+
+// sortedKeys 匹配通配符的键，按照字母排序
+func (c *FakeContainer) sortedKeys(pattern, kind string) []string {
+	var keys []string
+	for key := range c.data {
+		c.expireIfNeeded(key)
+	}
+	for key, e := range c.data {
+		if kind != "" && e.kind != kind {
+			continue
+		}
+		if ok, _ := path.Match(pattern, key); pattern == "" || ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// scanOptions 解析 MATCH COUNT TYPE，替身一次返回全部结果
+func scanOptions(args []string) (match, kind string, err error) {
+	for i := 0; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return "", "", errFakeSyntax
+		}
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			match = args[i+1]
+		case "TYPE":
+			kind = strings.ToLower(args[i+1])
+		case "COUNT":
+		default:
+			return "", "", errFakeSyntax
+		}
+	}
+	return
+}
+
+func scanMatch(match, value string) bool {
+	ok, _ := path.Match(match, value)
+	return match == "" || ok
+}
+
+// do 执行一个命令，调用者需要持有mu
+func (c *FakeContainer) do(name string, args []string) (any, error) {
+	arity := map[string]int{
+		"GET": 1, "SET": 2, "SETEX": 3, "PSETEX": 3, "SETNX": 2, "GETSET": 2, "GETDEL": 1,
+		"STRLEN": 1, "SETRANGE": 3, "GETRANGE": 3, "APPEND": 2,
+		"INCR": 1, "DECR": 1, "INCRBY": 2, "DECRBY": 2, "INCRBYFLOAT": 2,
+		"MGET": 1, "MSET": 2, "DEL": 1, "UNLINK": 1, "EXISTS": 1, "TYPE": 1,
+		"EXPIRE": 2, "PEXPIRE": 2, "TTL": 1, "PTTL": 1, "PERSIST": 1, "RENAME": 2,
+		"KEYS": 1, "SCAN": 1, "HGET": 2, "HSET": 3, "HMSET": 3, "HSETNX": 3, "HMGET": 2,
+		"HGETALL": 1, "HDEL": 2, "HEXISTS": 2, "HKEYS": 1, "HVALS": 1, "HLEN": 1,
+		"HINCRBY": 3, "HSCAN": 2, "LPUSH": 2, "RPUSH": 2, "LPOP": 1, "RPOP": 1,
+		"LLEN": 1, "LRANGE": 3, "LINDEX": 2, "LSET": 3, "LTRIM": 3, "LREM": 3,
+		"SADD": 2, "SREM": 2, "SMEMBERS": 1, "SISMEMBER": 2, "SMISMEMBER": 2,
+		"SCARD": 1, "SSCAN": 2, "SINTER": 1, "SUNION": 1, "SDIFF": 1, "PUBLISH": 2,
+		"ZADD": 3, "ZINCRBY": 3, "ZREM": 2, "ZSCORE": 2, "ZCARD": 1,
+		"ZRANK": 2, "ZREVRANK": 2, "ZRANGE": 3, "ZREVRANGE": 3,
+	}
+	if err := fakeArity(args, arity[name], name); err != nil {
+		return nil, err
+	}
+	now := c.getNow()
+	switch name {
+	case "PING":
+		if len(args) > 0 {
+			return []byte(args[0]), nil
+		}
+		return "PONG", nil
+	case "SELECT":
+		return "OK", nil
+	case "DBSIZE":
+		return int64(len(c.sortedKeys("", ""))), nil
+	case "FLUSHDB", "FLUSHALL":
+		for key := range c.data {
+			c.touch(key)
+		}
+		c.data = make(map[string]*fakeEntry)
+		return "OK", nil
+	case "PUBLISH":
+		return int64(0), nil
+
+	// 字符串
+	case "GET":
+		e, err := c.lookup(args[0], "string")
+		if e == nil {
+			return nil, err
+		}
+		return slices.Clone(e.str), nil
+	case "SET":
+		var expireAt time.Time
+		nx, xx, keepTTL := false, false, false
+		for i := 2; i < len(args); i++ {
+			switch opt := strings.ToUpper(args[i]); opt {
+			case "NX":
+				nx = true
+			case "XX":
+				xx = true
+			case "KEEPTTL":
+				keepTTL = true
+			case "EX", "PX":
+				if i++; i >= len(args) {
+					return nil, errFakeSyntax
+				}
+				num, err := strconv.ParseInt(args[i], 10, 64)
+				if err != nil || num <= 0 {
+					return nil, redis.Error("ERR invalid expire time in 'set' command")
+				}
+				unit := time.Second
+				if opt == "PX" {
+					unit = time.Millisecond
+				}
+				expireAt = now.Add(time.Duration(num) * unit)
+			default:
+				return nil, errFakeSyntax
+			}
+		}
+		old, _ := c.lookup(args[0], "")
+		if (nx && old != nil) || (xx && old == nil) {
+			return nil, nil
+		}
+		if keepTTL && old != nil {
+			expireAt = old.expireAt
+		}
+		c.setString(args[0], []byte(args[1]), expireAt)
+		return "OK", nil
+	case "SETEX", "PSETEX":
+		num, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || num <= 0 {
+			return nil, redis.Error(fmt.Sprintf("ERR invalid expire time in '%s' command", strings.ToLower(name)))
+		}
+		unit := time.Second
+		if name == "PSETEX" {
+			unit = time.Millisecond
+		}
+		c.setString(args[0], []byte(args[2]), now.Add(time.Duration(num)*unit))
+		return "OK", nil
+	case "SETNX":
+		if old, _ := c.lookup(args[0], ""); old != nil {
+			return int64(0), nil
+		}
+		c.setString(args[0], []byte(args[1]), time.Time{})
+		return int64(1), nil
+	case "GETSET", "GETDEL":
+		e, err := c.lookup(args[0], "string")
+		if err != nil {
+			return nil, err
+		}
+		var old any
+		if e != nil {
+			old = e.str
+		}
+		if name == "GETSET" {
+			c.setString(args[0], []byte(args[1]), time.Time{})
+		} else if e != nil {
+			delete(c.data, args[0])
+			c.touch(args[0])
+		}
+		return old, nil
+	case "STRLEN":
+		e, err := c.lookup(args[0], "string")
+		if e == nil {
+			return int64(0), err
+		}
+		return int64(len(e.str)), nil
+	case "APPEND":
+		e, err := c.lookupOrCreate(args[0], "string")
+		if err != nil {
+			return nil, err
+		}
+		e.str = append(e.str, args[1]...)
+		c.touch(args[0])
+		return int64(len(e.str)), nil
+	case "SETRANGE":
+		offset, err := strconv.Atoi(args[1])
+		if err != nil || offset < 0 {
+			return nil, redis.Error("ERR offset is out of range")
+		}
+		e, err := c.lookupOrCreate(args[0], "string")
+		if err != nil {
+			return nil, err
+		}
+		if size := offset + len(args[2]); size > len(e.str) {
+			e.str = append(e.str, make([]byte, size-len(e.str))...)
+		}
+		copy(e.str[offset:], args[2])
+		c.touch(args[0])
+		return int64(len(e.str)), nil
+	case "GETRANGE":
+		start, err1 := strconv.Atoi(args[1])
+		stop, err2 := strconv.Atoi(args[2])
+		if err1 != nil || err2 != nil {
+			return nil, errFakeNotInt
+		}
+		e, err := c.lookup(args[0], "string")
+		if e == nil {
+			return []byte{}, err
+		}
+		start, end := fakeRange(start, stop, len(e.str))
+		return slices.Clone(e.str[start:end]), nil
+	case "INCR", "DECR", "INCRBY", "DECRBY":
+		delta := int64(1)
+		if len(args) > 1 && (name == "INCRBY" || name == "DECRBY") {
+			var err error
+			if delta, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+				return nil, errFakeNotInt
+			}
+		}
+		if name == "DECR" || name == "DECRBY" {
+			delta = -delta
+		}
+		return c.incrBy(args[0], delta)
+	case "INCRBYFLOAT":
+		delta, err := strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return nil, errFakeNotFloat
+		}
+		e, err := c.lookupOrCreate(args[0], "string")
+		if err != nil {
+			return nil, err
+		}
+		var num float64
+		if len(e.str) > 0 {
+			if num, err = strconv.ParseFloat(string(e.str), 64); err != nil {
+				return nil, errFakeNotFloat
+			}
+		}
+		e.str = []byte(strconv.FormatFloat(num+delta, 'f', -1, 64))
+		c.touch(args[0])
+		return slices.Clone(e.str), nil
+	case "MGET":
+		result := make([]any, len(args))
+		for i, key := range args {
+			if e, _ := c.lookup(key, ""); e != nil && e.kind == "string" {
+				result[i] = slices.Clone(e.str)
+			}
+		}
+		return result, nil
+	case "MSET":
+		if len(args)%2 != 0 {
+			return nil, fakeArity(nil, 1, name)
+		}
+		for i := 0; i+1 < len(args); i += 2 {
+			c.setString(args[i], []byte(args[i+1]), time.Time{})
+		}
+		return "OK", nil
+
+	// 键
+	case "DEL", "UNLINK":
+		var count int64
+		for _, key := range args {
+			if e, _ := c.lookup(key, ""); e != nil {
+				delete(c.data, key)
+				c.touch(key)
+				count++
+			}
+		}
+		return count, nil
+	case "EXISTS":
+		var count int64
+		for _, key := range args {
+			if e, _ := c.lookup(key, ""); e != nil {
+				count++
+			}
+		}
+		return count, nil
+	case "TYPE":
+		if e, _ := c.lookup(args[0], ""); e != nil {
+			return e.kind, nil
+		}
+		return "none", nil
+	case "EXPIRE", "PEXPIRE":
+		num, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return nil, errFakeNotInt
+		}
+		unit := time.Second
+		if name == "PEXPIRE" {
+			unit = time.Millisecond
+		}
+		return c.expireAt(args[0], now.Add(time.Duration(num)*unit)), nil
+	case "TTL":
+		return c.ttl(args[0], time.Second), nil
+	case "PTTL":
+		return c.ttl(args[0], time.Millisecond), nil
+	case "PERSIST":
+		e, _ := c.lookup(args[0], "")
+		if e == nil || e.expireAt.IsZero() {
+			return int64(0), nil
+		}
+		e.expireAt = time.Time{}
+		c.touch(args[0])
+		return int64(1), nil
+	case "RENAME":
+		e, _ := c.lookup(args[0], "")
+		if e == nil {
+			return nil, errFakeNoKey
+		}
+		delete(c.data, args[0])
+		c.data[args[1]] = e
+		c.touch(args[0])
+		c.touch(args[1])
+		return "OK", nil
+	case "KEYS":
+		return fakeBulks(c.sortedKeys(args[0], "")), nil
+	case "SCAN":
+		match, kind, err := scanOptions(args[1:])
+		if err != nil {
+			return nil, err
+		}
+		return []any{[]byte("0"), fakeBulks(c.sortedKeys(match, kind))}, nil
+
+	// 哈希表
+	case "HGET":
+		e, err := c.lookup(args[0], "hash")
+		if e == nil {
+			return nil, err
+		}
+		if value, ok := e.hash[args[1]]; ok {
+			return slices.Clone(value), nil
+		}
+		return nil, nil
+	case "HSET", "HMSET":
+		if len(args)%2 != 1 {
+			return nil, fakeArity(nil, 1, name)
+		}
+		e, err := c.lookupOrCreate(args[0], "hash")
+		if err != nil {
+			return nil, err
+		}
+		var added int64
+		for i := 1; i+1 < len(args); i += 2 {
+			if _, ok := e.hash[args[i]]; !ok {
+				added++
+			}
+			e.hash[args[i]] = []byte(args[i+1])
+		}
+		c.touch(args[0])
+		if name == "HMSET" {
+			return "OK", nil
+		}
+		return added, nil
+	case "HSETNX":
+		e, err := c.lookupOrCreate(args[0], "hash")
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := e.hash[args[1]]; ok {
+			return int64(0), nil
+		}
+		e.hash[args[1]] = []byte(args[2])
+		c.touch(args[0])
+		return int64(1), nil
+	case "HMGET":
+		e, err := c.lookup(args[0], "hash")
+		if err != nil {
+			return nil, err
+		}
+		result := make([]any, len(args)-1)
+		for i, field := range args[1:] {
+			if e != nil {
+				if value, ok := e.hash[field]; ok {
+					result[i] = slices.Clone(value)
+				}
+			}
+		}
+		return result, nil
+	case "HGETALL", "HKEYS", "HVALS":
+		e, err := c.lookup(args[0], "hash")
+		if e == nil {
+			return []any{}, err
+		}
+		fields := make([]string, 0, len(e.hash))
+		for field := range e.hash {
+			fields = append(fields, field)
+		}
+		slices.Sort(fields)
+		var result []any
+		for _, field := range fields {
+			if name != "HVALS" {
+				result = append(result, []byte(field))
+			}
+			if name != "HKEYS" {
+				result = append(result, slices.Clone(e.hash[field]))
+			}
+		}
+		return result, nil
+	case "HDEL":
+		e, err := c.lookup(args[0], "hash")
+		if e == nil {
+			return int64(0), err
+		}
+		var count int64
+		for _, field := range args[1:] {
+			if _, ok := e.hash[field]; ok {
+				delete(e.hash, field)
+				count++
+			}
+		}
+		c.removeIfEmpty(args[0], e)
+		c.touch(args[0])
+		return count, nil
+	case "HEXISTS":
+		e, err := c.lookup(args[0], "hash")
+		if e == nil {
+			return int64(0), err
+		}
+		_, ok := e.hash[args[1]]
+		return fakeBool(ok), nil
+	case "HLEN":
+		e, err := c.lookup(args[0], "hash")
+		if e == nil {
+			return int64(0), err
+		}
+		return int64(len(e.hash)), nil
+	case "HINCRBY":
+		delta, err := strconv.ParseInt(args[2], 10, 64)
+		if err != nil {
+			return nil, errFakeNotInt
+		}
+		e, err := c.lookupOrCreate(args[0], "hash")
+		if err != nil {
+			return nil, err
+		}
+		var num int64
+		if value, ok := e.hash[args[1]]; ok {
+			if num, err = strconv.ParseInt(string(value), 10, 64); err != nil {
+				return nil, redis.Error("ERR hash value is not an integer")
+			}
+		}
+		num += delta
+		e.hash[args[1]] = []byte(strconv.FormatInt(num, 10))
+		c.touch(args[0])
+		return num, nil
+	case "HSCAN":
+		match, _, err := scanOptions(args[2:])
+		if err != nil {
+			return nil, err
+		}
+		e, err := c.lookup(args[0], "hash")
+		if err != nil {
+			return nil, err
+		}
+		var items []any
+		if e != nil {
+			fields := make([]string, 0, len(e.hash))
+			for field := range e.hash {
+				if scanMatch(match, field) {
+					fields = append(fields, field)
+				}
+			}
+			slices.Sort(fields)
+			for _, field := range fields {
+				items = append(items, []byte(field), slices.Clone(e.hash[field]))
+			}
+		}
+		return []any{[]byte("0"), items}, nil
+
+	// 列表
+	case "LPUSH", "RPUSH":
+		e, err := c.lookupOrCreate(args[0], "list")
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range args[1:] {
+			if name == "LPUSH" {
+				e.list = append([][]byte{[]byte(value)}, e.list...)
+			} else {
+				e.list = append(e.list, []byte(value))
+			}
+		}
+		c.touch(args[0])
+		return int64(len(e.list)), nil
+	case "LPOP", "RPOP":
+		e, err := c.lookup(args[0], "list")
+		if e == nil {
+			return nil, err
+		}
+		count, multi := 1, len(args) > 1
+		if multi {
+			if count, err = strconv.Atoi(args[1]); err != nil || count < 0 {
+				return nil, errFakeNotInt
+			}
+		}
+		count = min(count, len(e.list))
+		popped := make([]any, count)
+		for i := range popped {
+			if name == "LPOP" {
+				popped[i], e.list = e.list[0], e.list[1:]
+			} else {
+				last := len(e.list) - 1
+				popped[i], e.list = e.list[last], e.list[:last]
+			}
+		}
+		c.removeIfEmpty(args[0], e)
+		c.touch(args[0])
+		if multi {
+			return popped, nil
+		} else if count == 0 {
+			return nil, nil
+		}
+		return popped[0], nil
+	case "LLEN":
+		e, err := c.lookup(args[0], "list")
+		if e == nil {
+			return int64(0), err
+		}
+		return int64(len(e.list)), nil
+	case "LRANGE":
+		start, err1 := strconv.Atoi(args[1])
+		stop, err2 := strconv.Atoi(args[2])
+		if err1 != nil || err2 != nil {
+			return nil, errFakeNotInt
+		}
+		e, err := c.lookup(args[0], "list")
+		if e == nil {
+			return []any{}, err
+		}
+		start, end := fakeRange(start, stop, len(e.list))
+		result := make([]any, 0, end-start)
+		for _, value := range e.list[start:end] {
+			result = append(result, slices.Clone(value))
+		}
+		return result, nil
+	case "LINDEX", "LSET":
+		index, err := strconv.Atoi(args[1])
+		if err != nil {
+			return nil, errFakeNotInt
+		}
+		e, err := c.lookup(args[0], "list")
+		if err != nil {
+			return nil, err
+		} else if e == nil && name == "LSET" {
+			return nil, errFakeNoKey
+		}
+		size := 0
+		if e != nil {
+			size = len(e.list)
+		}
+		if index < 0 {
+			index += size
+		}
+		if index < 0 || index >= size {
+			if name == "LSET" {
+				return nil, redis.Error("ERR index out of range")
+			}
+			return nil, nil
+		}
+		if name == "LINDEX" {
+			return slices.Clone(e.list[index]), nil
+		}
+		e.list[index] = []byte(args[2])
+		c.touch(args[0])
+		return "OK", nil
+	case "LTRIM":
+		start, err1 := strconv.Atoi(args[1])
+		stop, err2 := strconv.Atoi(args[2])
+		if err1 != nil || err2 != nil {
+			return nil, errFakeNotInt
+		}
+		e, err := c.lookup(args[0], "list")
+		if e == nil {
+			return "OK", err
+		}
+		start, end := fakeRange(start, stop, len(e.list))
+		e.list = e.list[start:end]
+		c.removeIfEmpty(args[0], e)
+		c.touch(args[0])
+		return "OK", nil
+	case "LREM":
+		count, err := strconv.Atoi(args[1])
+		if err != nil {
+			return nil, errFakeNotInt
+		}
+		e, err := c.lookup(args[0], "list")
+		if e == nil {
+			return int64(0), err
+		}
+		var removed int64
+		if count < 0 { // 从右往左删除
+			for i := len(e.list) - 1; i >= 0 && (removed < int64(-count)); i-- {
+				if string(e.list[i]) == args[2] {
+					e.list = slices.Delete(e.list, i, i+1)
+					removed++
+				}
+			}
+		} else {
+			for i := 0; i < len(e.list) && (count == 0 || removed < int64(count)); {
+				if string(e.list[i]) == args[2] {
+					e.list = slices.Delete(e.list, i, i+1)
+					removed++
+				} else {
+					i++
+				}
+			}
+		}
+		c.removeIfEmpty(args[0], e)
+		c.touch(args[0])
+		return removed, nil
+
+	// 集合
+	case "SADD":
+		e, err := c.lookupOrCreate(args[0], "set")
+		if err != nil {
+			return nil, err
+		}
+		var added int64
+		for _, member := range args[1:] {
+			if _, ok := e.set[member]; !ok {
+				e.set[member] = struct{}{}
+				added++
+			}
+		}
+		c.touch(args[0])
+		return added, nil
+	case "SREM":
+		e, err := c.lookup(args[0], "set")
+		if e == nil {
+			return int64(0), err
+		}
+		var removed int64
+		for _, member := range args[1:] {
+			if _, ok := e.set[member]; ok {
+				delete(e.set, member)
+				removed++
+			}
+		}
+		c.removeIfEmpty(args[0], e)
+		c.touch(args[0])
+		return removed, nil
+	case "SMEMBERS", "SSCAN":
+		match := ""
+		if name == "SSCAN" {
+			var err error
+			if match, _, err = scanOptions(args[2:]); err != nil {
+				return nil, err
+			}
+		}
+		e, err := c.lookup(args[0], "set")
+		if err != nil {
+			return nil, err
+		}
+		var members []string
+		if e != nil {
+			for member := range e.set {
+				if scanMatch(match, member) {
+					members = append(members, member)
+				}
+			}
+		}
+		slices.Sort(members)
+		if name == "SSCAN" {
+			return []any{[]byte("0"), fakeBulks(members)}, nil
+		}
+		return fakeBulks(members), nil
+	case "SISMEMBER", "SMISMEMBER":
+		e, err := c.lookup(args[0], "set")
+		if err != nil {
+			return nil, err
+		}
+		result := make([]any, len(args)-1)
+		for i, member := range args[1:] {
+			ok := false
+			if e != nil {
+				_, ok = e.set[member]
+			}
+			result[i] = fakeBool(ok)
+		}
+		if name == "SISMEMBER" {
+			return result[0], nil
+		}
+		return result, nil
+	case "SCARD":
+		e, err := c.lookup(args[0], "set")
+		if e == nil {
+			return int64(0), err
+		}
+		return int64(len(e.set)), nil
+	case "SINTER", "SUNION", "SDIFF":
+		members, err := c.setAlgebra(name, args)
+		if err != nil {
+			return nil, err
+		}
+		return fakeBulks(members), nil
+
+	// 有序集合
+	case "ZADD", "ZINCRBY":
+		if name == "ZADD" && len(args)%2 == 0 {
+			return nil, errFakeSyntax
+		} else if name == "ZINCRBY" && len(args) != 3 {
+			return nil, errFakeSyntax
+		}
+		e, err := c.lookupOrCreate(args[0], "zset")
+		if err != nil {
+			return nil, err
+		}
+		var added int64
+		for i := 1; i+1 < len(args); i += 2 {
+			score, err := strconv.ParseFloat(args[i], 64)
+			if err != nil {
+				c.removeIfEmpty(args[0], e)
+				return nil, errFakeNotFloat
+			}
+			old, ok := e.zset[args[i+1]]
+			if !ok {
+				added++
+			}
+			if name == "ZINCRBY" {
+				score += old
+			}
+			e.zset[args[i+1]] = score
+		}
+		c.touch(args[0])
+		if name == "ZINCRBY" {
+			return []byte(strconv.FormatFloat(e.zset[args[2]], 'g', -1, 64)), nil
+		}
+		return added, nil
+	case "ZREM":
+		e, err := c.lookup(args[0], "zset")
+		if e == nil {
+			return int64(0), err
+		}
+		var removed int64
+		for _, member := range args[1:] {
+			if _, ok := e.zset[member]; ok {
+				delete(e.zset, member)
+				removed++
+			}
+		}
+		c.removeIfEmpty(args[0], e)
+		c.touch(args[0])
+		return removed, nil
+	case "ZSCORE":
+		e, err := c.lookup(args[0], "zset")
+		if e == nil {
+			return nil, err
+		}
+		if score, ok := e.zset[args[1]]; ok {
+			return []byte(strconv.FormatFloat(score, 'g', -1, 64)), nil
+		}
+		return nil, nil
+	case "ZCARD":
+		e, err := c.lookup(args[0], "zset")
+		if e == nil {
+			return int64(0), err
+		}
+		return int64(len(e.zset)), nil
+	case "ZRANK", "ZREVRANK":
+		e, err := c.lookup(args[0], "zset")
+		if e == nil {
+			return nil, err
+		}
+		for i, m := range fakeSortZSet(e.zset, name == "ZREVRANK") {
+			if m.Member == args[1] {
+				return int64(i), nil
+			}
+		}
+		return nil, nil
+	case "ZRANGE", "ZREVRANGE":
+		start, err1 := strconv.Atoi(args[1])
+		stop, err2 := strconv.Atoi(args[2])
+		if err1 != nil || err2 != nil {
+			return nil, errFakeNotInt
+		}
+		withScores := len(args) > 3 && strings.EqualFold(args[3], "WITHSCORES")
+		e, err := c.lookup(args[0], "zset")
+		if e == nil {
+			return []any{}, err
+		}
+		members := fakeSortZSet(e.zset, name == "ZREVRANGE")
+		start, end := fakeRange(start, stop, len(members))
+		var result []any
+		for _, m := range members[start:end] {
+			result = append(result, []byte(m.Member))
+			if withScores {
+				result = append(result, []byte(strconv.FormatFloat(m.Score, 'g', -1, 64)))
+			}
+		}
+		return result, nil
+	}
+	return nil, redis.Error(fmt.Sprintf("ERR unknown command '%s'", strings.ToLower(name)))
+}
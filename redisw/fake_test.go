@@ -0,0 +1,38 @@
+package redisw_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/azhai/xgen/redisw"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeExpire(t *testing.T) {
+	r := redisw.NewRedisFake()
+	fake := r.RedisContainer.(*redisw.FakeContainer)
+	fake.SetNow(time.Now())
+	r.SetVal("test:a", 39, 60)
+	a, err := r.GetInt("test:a")
+	assert.NoError(t, err)
+	assert.Equal(t, 39, a)
+	fake.Advance(2 * time.Second)
+	assert.Equal(t, 58, r.GetTimeout("test:a"))
+	fake.Advance(time.Minute)
+	assert.Equal(t, -2, r.GetTimeout("test:a"))
+
+	r.SaveMap(redisw.Map{"test:b": 1, "test:c": 2}, false)
+	keys, err := r.Find("test:*")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test:b", "test:c"}, keys)
+	assert.Equal(t, 2, r.GetSize())
+	r.DeleteAll()
+	assert.Equal(t, 0, r.GetSize())
+
+	_, err = r.Tx(func(p *redisw.Pipeline) error {
+		r.SetVal("test:b", 3, 60) // 其他连接修改了WATCH的键
+		p.Send("INCR", "test:b")
+		return nil
+	}, "test:b")
+	assert.ErrorIs(t, err, redisw.TxAbortedError)
+}
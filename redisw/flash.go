@@ -0,0 +1,150 @@
+package redisw
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// 临时消息的类别
+const (
+	FLASH_INFO  = "info"
+	FLASH_WARN  = "warn"
+	FLASH_ERROR = "error"
+)
+
+// FlashMessage 带类别的临时消息，内容为任意Json
+type FlashMessage struct {
+	Category string          `json:"cat"`
+	Payload  json.RawMessage `json:"data,omitempty"`
+}
+
+// NewFlashMessage 创建临时消息，payload编码为Json
+func NewFlashMessage(category string, payload any) (FlashMessage, error) {
+	msg := FlashMessage{Category: category}
+	if payload == nil {
+		return msg, nil
+	}
+	body, err := json.Marshal(payload)
+	msg.Payload = body
+	return msg, err
+}
+
+// ParseFlashMessage 解析列表中的一条消息，旧的纯文本消息作为info
+func ParseFlashMessage(data string) FlashMessage {
+	var msg FlashMessage
+	if err := json.Unmarshal([]byte(data), &msg); err == nil && msg.Category != "" {
+		return msg
+	}
+	body, _ := json.Marshal(data)
+	return FlashMessage{Category: FLASH_INFO, Payload: body}
+}
+
+// Decode 将内容解码到v中
+func (m FlashMessage) Decode(v any) error {
+	if len(m.Payload) == 0 {
+		return nil
+	}
+	return json.Unmarshal(m.Payload, v)
+}
+
+// String 编码后保存到列表中
+func (m FlashMessage) String() string {
+	body, _ := json.Marshal(m)
+	return string(body)
+}
+
+func parseFlashMessages(data []string, err error) ([]FlashMessage, error) {
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]FlashMessage, len(data))
+	for i, item := range data {
+		messages[i] = ParseFlashMessage(item)
+	}
+	return messages, nil
+}
+
+// rangeFlashes 读取最多n条消息，旧键名中的消息更早，排在前面
+func rangeFlashes(r *RedisWrapper, sessKey string, n int) ([]string, error) {
+	end := -1
+	if n > 0 {
+		end = n - 1
+	}
+	p := r.Pipeline().Send("LRANGE", legacyFlashKey(sessKey), 0, end)
+	replies, err := p.Send("LRANGE", r.GetFlashKey(sessKey), 0, end).Exec()
+	if err != nil {
+		return nil, err
+	}
+	flashes, err := replies.Strings(0)
+	if err != nil {
+		return nil, err
+	}
+	more, err := replies.Strings(1)
+	flashes = append(flashes, more...)
+	if n > 0 && len(flashes) > n {
+		flashes = flashes[:n]
+	}
+	return flashes, err
+}
+
+// popFlashes 取出并删除最多n条消息，先取旧键名中的消息
+func popFlashes(r *RedisWrapper, sessKey string, n int) ([]string, error) {
+	flashes, err := popList(r, legacyFlashKey(sessKey), n)
+	if err != nil || (n > 0 && len(flashes) >= n) {
+		return flashes, err
+	}
+	if n > 0 {
+		n -= len(flashes)
+	}
+	more, err := popList(r, r.GetFlashKey(sessKey), n)
+	return append(flashes, more...), err
+}
+
+// popList 在事务中用 LRANGE 和 LTRIM 原子地取出列表前面n条，n<=0时全部取出
+// 连接复用不支持事务，改用 LPOP key count ，需要redis 6.2以上
+func popList(r *RedisWrapper, key string, n int) ([]string, error) {
+	replies, err := r.Tx(func(p *Pipeline) error {
+		if n > 0 {
+			p.Send("LRANGE", key, 0, n-1).Send("LTRIM", key, n, -1)
+		} else {
+			p.Send("LRANGE", key, 0, -1).Send("DEL", key)
+		}
+		return nil
+	})
+	if errors.Is(err, TxUnsupportedError) {
+		if n <= 0 {
+			n = math.MaxInt32
+		}
+		flashes, err := redis.Strings(r.Exec("LPOP", key, n))
+		if errors.Is(err, redis.ErrNil) {
+			err = nil
+		}
+		return flashes, err
+	} else if err != nil {
+		return nil, err
+	}
+	return replies.Strings(0)
+}
+
+// pushFlashes 添加消息，列表的过期时间和会话一致，会话不存在时使用timeout
+func pushFlashes(r *RedisWrapper, sessKey string, timeout int, messages ...string) (int, error) {
+	if len(messages) == 0 {
+		return 0, KeysEmptyError
+	}
+	if ttl := r.GetTimeout(sessKey); ttl > 0 || ttl == SESS_FOR_EVER {
+		timeout = ttl
+	}
+	flashKey := r.GetFlashKey(sessKey)
+	p := r.Pipeline().Send("RPUSH", append([]any{flashKey}, StrToList(messages)...)...)
+	if timeout > 0 {
+		p.Send("EXPIRE", flashKey, timeout)
+	}
+	replies, err := p.Exec()
+	if err != nil {
+		return 0, err
+	}
+	return replies.Int(0)
+}
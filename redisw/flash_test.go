@@ -0,0 +1,81 @@
+package redisw_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/azhai/xgen/redisw"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeFlash(t *testing.T) {
+	r := redisw.NewRedisFake()
+	reg := redisw.NewRegistry(r)
+	sess := reg.GetSession("old", 60)
+	sess.BindRoles("u1", nil, false)
+	sess.AddFlash("plain")
+	sess.PushFlash(redisw.FLASH_WARN, map[string]int{"left": 3})
+	sess.PushFlash(redisw.FLASH_ERROR, "failed")
+	assert.Equal(t, 60, r.GetTimeout("flash:{sess:old}"))
+
+	fake := r.RedisContainer.(*redisw.FakeContainer)
+	fake.Advance(20 * time.Second) // 更换token不会重置过期时间
+	sess, err := reg.RotateSession("old", "new", 60)
+	assert.NoError(t, err)
+	assert.Equal(t, 40, r.GetTimeout("sess:new"))
+	assert.Equal(t, 40, r.GetTimeout("flash:{sess:new}"))
+	tokens, _ := reg.ListSessions("u1")
+	assert.Equal(t, []string{"new"}, tokens)
+	token, _ := sess.GetString(redisw.SESS_TOKEN_KEY)
+	assert.Equal(t, "new", token)
+	_, err = reg.RotateSession("old", "other", 60)
+	assert.ErrorIs(t, err, redis.ErrNil)
+
+	msgs, err := sess.PopFlashes(2)
+	assert.NoError(t, err)
+	assert.Equal(t, redisw.FLASH_INFO, msgs[0].Category)
+	var text string
+	assert.NoError(t, msgs[0].Decode(&text))
+	assert.Equal(t, "plain", text)
+	var left map[string]int
+	assert.NoError(t, msgs[1].Decode(&left))
+	assert.Equal(t, 3, left["left"])
+	msgs, _ = sess.PopFlashes(-1)
+	assert.Len(t, msgs, 1)
+	assert.Equal(t, redisw.FLASH_ERROR, msgs[0].Category)
+	msgs, err = sess.PopFlashes(-1)
+	assert.NoError(t, err)
+	assert.Empty(t, msgs)
+
+	sess.PushFlash(redisw.FLASH_INFO, nil)
+	ok, err := sess.Expire(120)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 120, r.GetTimeout("flash:{sess:new}"))
+	sess.PopFlashes(-1)
+
+	// 旧版本的临时消息仍然可以读取，排在新消息前面
+	r.Exec("RPUSH", "flash:sess:new", "legacy-1", "legacy-2")
+	sess.AddFlash("current")
+	flashes, err := sess.GetFlashes(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"legacy-1", "legacy-2"}, flashes)
+	msgs, err = sess.PopFlashes(1)
+	assert.NoError(t, err)
+	assert.NoError(t, msgs[0].Decode(&text))
+	assert.Equal(t, "legacy-1", text)
+	msgs, _ = sess.PopFlashes(-1)
+	assert.Len(t, msgs, 2)
+	exists, _ := r.Exec("EXISTS", "flash:sess:new", "flash:{sess:new}")
+	assert.Equal(t, int64(0), exists)
+
+	store := redisw.NewStoreSession(redisw.NewMemorySessionStore(), "a", 60)
+	store.PushFlash(redisw.FLASH_INFO, "hi")
+	assert.NoError(t, store.Rotate("b"))
+	assert.Equal(t, "sess:b", store.GetKey())
+	msgs, _ = store.PopFlashes(-1)
+	assert.Len(t, msgs, 1)
+	msgs, _ = store.PopFlashes(-1)
+	assert.Empty(t, msgs)
+}
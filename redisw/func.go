@@ -14,7 +14,12 @@ func (r *RedisWrapper) SetPart(key string, value any, offset int) (int, error) {
 	return redis.Int(reply, err)
 }
 
+// SetVal 写入并设置过期时间，timeout不大于0时永不过期
 func (r *RedisWrapper) SetVal(key string, value any, timeout int) (bool, error) {
+	if timeout <= 0 {
+		reply, err := r.Exec("SET", key, value)
+		return ReplyBool(reply, err)
+	}
 	reply, err := r.Exec("SETEX", key, timeout, value)
 	return ReplyBool(reply, err)
 }
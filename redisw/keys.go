@@ -0,0 +1,123 @@
+package redisw
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/azhai/xgen/dialect"
+)
+
+// 不带键的命令
+var noKeyCommands = map[string]bool{
+	"PING": true, "ECHO": true, "INFO": true, "TIME": true, "ROLE": true,
+	"DBSIZE": true, "FLUSHDB": true, "FLUSHALL": true, "SELECT": true,
+	"SCAN": true, "KEYS": true, "RANDOMKEY": true, "PUBLISH": true,
+	"SUBSCRIBE": true, "PSUBSCRIBE": true, "UNSUBSCRIBE": true, "PUNSUBSCRIBE": true,
+	"MULTI": true, "EXEC": true, "DISCARD": true, "UNWATCH": true,
+	"AUTH": true, "HELLO": true, "QUIT": true, "ASKING": true, "READONLY": true,
+	"CLUSTER": true, "CONFIG": true, "CLIENT": true, "SCRIPT": true, "SENTINEL": true,
+	"COMMAND": true, "FUNCTION": true, "ACL": true, "PUBSUB": true, "SLOWLOG": true,
+	"LATENCY": true, "DEBUG": true, "MONITOR": true, "WAIT": true, "SWAPDB": true,
+	"SAVE": true, "BGSAVE": true, "BGREWRITEAOF": true, "LASTSAVE": true, "RESET": true,
+}
+
+// keySpec 参数中键的位置，last为负数时从后往前数，step为间隔
+type keySpec struct {
+	first, last, step int
+}
+
+// 带多个键的命令，其他命令只有第一个参数是键
+var multiKeySpecs = map[string]keySpec{
+	"DEL": {0, -1, 1}, "UNLINK": {0, -1, 1}, "EXISTS": {0, -1, 1}, "TOUCH": {0, -1, 1},
+	"MGET": {0, -1, 1}, "WATCH": {0, -1, 1}, "MSET": {0, -1, 2}, "MSETNX": {0, -1, 2},
+	"RENAME": {0, 1, 1}, "RENAMENX": {0, 1, 1}, "COPY": {0, 1, 1},
+	"SMOVE": {0, 1, 1}, "RPOPLPUSH": {0, 1, 1}, "LMOVE": {0, 1, 1},
+	"SINTER": {0, -1, 1}, "SUNION": {0, -1, 1}, "SDIFF": {0, -1, 1},
+	"SINTERSTORE": {0, -1, 1}, "SUNIONSTORE": {0, -1, 1}, "SDIFFSTORE": {0, -1, 1},
+	"PFCOUNT": {0, -1, 1}, "PFMERGE": {0, -1, 1},
+	"BLPOP": {0, -2, 1}, "BRPOP": {0, -2, 1}, // 最后一个参数是超时
+	"BZPOPMIN": {0, -2, 1}, "BZPOPMAX": {0, -2, 1}, "BRPOPLPUSH": {0, 1, 1}, "BLMOVE": {0, 1, 1},
+	"ZRANGESTORE": {0, 1, 1}, "GEOSEARCHSTORE": {0, 1, 1}, "LCS": {0, 1, 1},
+	"BITOP": {1, -1, 1}, // BITOP op dest key ...
+}
+
+// numKeysSpec 由numkeys参数决定键数量的命令，pos是numkeys的位置，fixed是它之前的键
+type numKeysSpec struct {
+	pos   int
+	fixed []int
+}
+
+var numKeysSpecs = map[string]numKeysSpec{
+	"EVAL": {1, nil}, "EVALSHA": {1, nil}, "EVAL_RO": {1, nil}, "EVALSHA_RO": {1, nil},
+	"FCALL": {1, nil}, "FCALL_RO": {1, nil}, // script numkeys key ...
+	"ZUNIONSTORE": {1, []int{0}}, "ZINTERSTORE": {1, []int{0}}, "ZDIFFSTORE": {1, []int{0}},
+	"ZUNION": {0, nil}, "ZINTER": {0, nil}, "ZDIFF": {0, nil},
+	"ZINTERCARD": {0, nil}, "SINTERCARD": {0, nil}, "LMPOP": {0, nil}, "ZMPOP": {0, nil},
+	"BLMPOP": {1, nil}, "BZMPOP": {1, nil}, // timeout numkeys key ...
+}
+
+// CommandKeys 命令参数中哪些位置是键
+func CommandKeys(cmd string, args []any) []int {
+	name := strings.ToUpper(cmd)
+	if spec, ok := numKeysSpecs[name]; ok {
+		if len(args) <= spec.pos {
+			return nil
+		}
+		num, _ := strconv.Atoi(FormatValue(args[spec.pos]))
+		start := spec.pos + 1
+		return append(spec.fixed[:len(spec.fixed):len(spec.fixed)],
+			keyRange(start, min(start+max(num, 0), len(args)), 1)...)
+	}
+	switch name {
+	case "XREAD", "XREADGROUP": // ... STREAMS key ... id ...
+		for i, arg := range args {
+			if strings.EqualFold(FormatValue(arg), "STREAMS") {
+				num := (len(args) - i - 1) / 2
+				return keyRange(i+1, i+1+num, 1)
+			}
+		}
+		return nil
+	case "XGROUP", "XINFO", "OBJECT", "MEMORY": // 子命令之后是键
+		if len(args) < 2 {
+			return nil
+		}
+		return []int{1}
+	}
+	if noKeyCommands[name] || len(args) == 0 {
+		return nil
+	}
+	spec, ok := multiKeySpecs[name]
+	if !ok {
+		return []int{0}
+	}
+	last := spec.last
+	if last < 0 {
+		last += len(args)
+	}
+	return keyRange(spec.first, min(last+1, len(args)), spec.step)
+}
+
+func keyRange(start, end, step int) []int {
+	var result []int
+	for i := start; i < end; i += step {
+		result = append(result, i)
+	}
+	return result
+}
+
+// SummarizeArgs 命令的参数摘要，只保留键名，其他参数用?代替
+func SummarizeArgs(cmd string, args []any) string {
+	parts := make([]string, len(args)+1)
+	parts[0] = strings.ToUpper(cmd)
+	for i := range args {
+		parts[i+1] = "?"
+	}
+	for _, i := range CommandKeys(cmd, args) {
+		parts[i+1] = FormatValue(args[i])
+	}
+	summary := strings.Join(parts, " ")
+	if len(summary) > dialect.HOOK_MAX_SUMMARY {
+		summary = summary[:dialect.HOOK_MAX_SUMMARY] + "..."
+	}
+	return summary
+}
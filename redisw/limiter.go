@@ -0,0 +1,144 @@
+package redisw
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/azhai/xgen/utils"
+	"github.com/gomodule/redigo/redis"
+)
+
+var (
+	// 固定窗口，窗口内第一次请求时开始计时
+	fixedWindowScript = redis.NewScript(1, `
+local limit, window = tonumber(ARGV[1]), tonumber(ARGV[2])
+local n = redis.call("INCR", KEYS[1])
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl < 0 then
+	redis.call("PEXPIRE", KEYS[1], window)
+	ttl = window
+end
+if n > limit then
+	return {0, 0, ttl}
+end
+return {1, limit - n, 0}`)
+	// 滑动窗口日志，有序集合中记录每次请求的时间
+	slidingLogScript = redis.NewScript(1, `
+local limit, window = tonumber(ARGV[1]), tonumber(ARGV[2])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+local n = redis.call("ZCARD", KEYS[1])
+if n >= limit then
+	local first = redis.call("ZRANGE", KEYS[1], 0, 0, "WITHSCORES")
+	return {0, 0, tonumber(first[2]) + window - now}
+end
+redis.call("ZADD", KEYS[1], now, ARGV[3])
+redis.call("PEXPIRE", KEYS[1], window)
+return {1, limit - n - 1, 0}`)
+	// 令牌桶，哈希表中记录剩余令牌和上次补充的时间
+	tokenBucketScript = redis.NewScript(1, `
+local capacity, rate = tonumber(ARGV[1]), tonumber(ARGV[2])
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local data = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(data[1]) or capacity
+local ts = tonumber(data[2]) or now
+tokens = math.min(capacity, tokens + (now - ts) * rate / 1000)
+local allowed, retry = 0, 0
+if tokens >= 1 then
+	tokens, allowed = tokens - 1, 1
+else
+	retry = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call("HSET", KEYS[1], "tokens", tokens, "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(capacity * 1000 / rate) + 1000)
+return {allowed, math.floor(tokens), retry}`)
+)
+
+// LimitResult 限流的结果
+type LimitResult struct {
+	Allowed    bool          // 是否放行
+	Remaining  int           // 剩余的配额
+	RetryAfter time.Duration // 被拒绝时，多久之后可以重试
+}
+
+// Limiter 限流器，key由调用者决定，例如IP或用户ID
+type Limiter interface {
+	Allow(key string) (*LimitResult, error)
+}
+
+// ParseLimitResult 解析限流脚本的应答 {allowed, remaining, retry_ms}
+func ParseLimitResult(reply any, err error) (*LimitResult, error) {
+	var values []int64
+	if values, err = redis.Int64s(reply, err); err != nil {
+		return nil, err
+	} else if len(values) < 3 {
+		return nil, errors.New("the reply of limiter is invalid")
+	}
+	return &LimitResult{
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
+
+// FixedWindow 固定窗口限流，窗口交界处最多可能放行两倍请求
+type FixedWindow struct {
+	prefix string
+	limit  int
+	window time.Duration
+	*RedisWrapper
+}
+
+func NewFixedWindow(r *RedisWrapper, prefix string, limit int, window time.Duration) *FixedWindow {
+	return &FixedWindow{RedisWrapper: r, prefix: prefix, limit: limit, window: window}
+}
+
+func (l *FixedWindow) Allow(key string) (*LimitResult, error) {
+	key = utils.ConcatWith(l.prefix, key)
+	return ParseLimitResult(l.Eval(fixedWindowScript, key, l.limit, l.window.Milliseconds()))
+}
+
+// SlidingLog 滑动窗口日志限流，精确但每个请求占用一条记录
+type SlidingLog struct {
+	prefix string
+	limit  int
+	window time.Duration
+	*RedisWrapper
+}
+
+func NewSlidingLog(r *RedisWrapper, prefix string, limit int, window time.Duration) *SlidingLog {
+	return &SlidingLog{RedisWrapper: r, prefix: prefix, limit: limit, window: window}
+}
+
+func (l *SlidingLog) Allow(key string) (*LimitResult, error) {
+	member, err := newOwnerToken() // 同一毫秒内的请求也要区分
+	if err != nil {
+		return nil, err
+	}
+	key = utils.ConcatWith(l.prefix, key)
+	return ParseLimitResult(l.Eval(slidingLogScript, key, l.limit, l.window.Milliseconds(), member))
+}
+
+// TokenBucket 令牌桶限流，允许capacity个突发请求，之后每秒补充rate个
+type TokenBucket struct {
+	prefix   string
+	capacity int
+	rate     float64
+	*RedisWrapper
+}
+
+// NewTokenBucket 创建令牌桶，capacity和rate必须大于0
+func NewTokenBucket(r *RedisWrapper, prefix string, capacity int, rate float64) (*TokenBucket, error) {
+	if capacity <= 0 || !(rate > 0) {
+		return nil, fmt.Errorf("the token bucket needs positive capacity and rate, got %d and %v", capacity, rate)
+	}
+	return &TokenBucket{RedisWrapper: r, prefix: prefix, capacity: capacity, rate: rate}, nil
+}
+
+func (l *TokenBucket) Allow(key string) (*LimitResult, error) {
+	key = utils.ConcatWith(l.prefix, key)
+	return ParseLimitResult(l.Eval(tokenBucketScript, key, l.capacity, l.rate))
+}
@@ -0,0 +1,44 @@
+package redisw_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/azhai/xgen/redisw"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiters(t *testing.T) {
+	r := GetRedis(t)
+	r.DeleteMatching("test:limit:*", 0)
+	bucket, err := redisw.NewTokenBucket(r, "test:limit:bucket", 3, 1)
+	assert.NoError(t, err)
+	limiters := []redisw.Limiter{
+		redisw.NewFixedWindow(r, "test:limit:fixed", 3, time.Second),
+		redisw.NewSlidingLog(r, "test:limit:log", 3, time.Second),
+		bucket,
+	}
+	for _, lim := range limiters {
+		for i := 2; i >= 0; i-- {
+			res, err := lim.Allow("ip")
+			assert.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, i, res.Remaining)
+		}
+		res, err := lim.Allow("ip")
+		assert.NoError(t, err)
+		assert.False(t, res.Allowed)
+		assert.Greater(t, res.RetryAfter, time.Duration(0))
+		res, err = lim.Allow("other")
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+	}
+}
+
+func TestTokenBucketArgs(t *testing.T) {
+	r := redisw.NewRedisFake()
+	_, err := redisw.NewTokenBucket(r, "test:limit", 3, 0)
+	assert.Error(t, err)
+	_, err = redisw.NewTokenBucket(r, "test:limit", 0, 1)
+	assert.Error(t, err)
+}
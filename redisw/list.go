@@ -0,0 +1,126 @@
+package redisw
+
+import (
+	"github.com/gomodule/redigo/redis"
+)
+
+type RedisList struct {
+	name    string
+	timeout int
+	*RedisWrapper
+}
+
+func NewRedisList(r *RedisWrapper, name string, timeout int) *RedisList {
+	return &RedisList{RedisWrapper: r, name: name, timeout: timeout}
+}
+
+func (rl *RedisList) Exec(cmd string, args ...any) (any, error) {
+	args = append([]any{rl.name}, args...)
+	return rl.RedisWrapper.Exec(cmd, args...)
+}
+
+// GetSize 获取列表元素数量
+func (rl *RedisList) GetSize() int {
+	size, _ := redis.Int(rl.Exec("LLEN"))
+	return size
+}
+
+// GetTimeout 获取剩余时间 -1=无限 -2=不存在 -3=出错
+func (rl *RedisList) GetTimeout(predict bool) int {
+	timeout := rl.RedisWrapper.GetTimeout(rl.name)
+	if timeout == -2 && predict { // 尚未设置，使用预定值
+		timeout = rl.timeout
+	}
+	return timeout
+}
+
+func (rl *RedisList) Expire(timeout int) (bool, error) {
+	return rl.RedisWrapper.Expire(rl.name, timeout)
+}
+
+func (rl *RedisList) DeleteAll() (bool, error) {
+	affects, err := rl.RedisWrapper.Delete(rl.name)
+	return affects > 0, err
+}
+
+// Push 从右边添加，返回列表长度
+func (rl *RedisList) Push(values ...any) (int, error) {
+	if len(values) == 0 {
+		return 0, KeysEmptyError
+	}
+	defer rl.Exec("EXPIRE", rl.timeout)
+	return redis.Int(rl.Exec("RPUSH", values...))
+}
+
+// PushLeft 从左边添加，返回列表长度
+func (rl *RedisList) PushLeft(values ...any) (int, error) {
+	if len(values) == 0 {
+		return 0, KeysEmptyError
+	}
+	defer rl.Exec("EXPIRE", rl.timeout)
+	return redis.Int(rl.Exec("LPUSH", values...))
+}
+
+func (rl *RedisList) PushStrings(values ...string) (int, error) {
+	return rl.Push(StrToList(values)...)
+}
+
+// Pop 从右边或左边取出一个元素
+func (rl *RedisList) Pop(left bool) (any, error) {
+	if left {
+		return rl.Exec("LPOP")
+	}
+	return rl.Exec("RPOP")
+}
+
+func (rl *RedisList) PopString(left bool) (string, error) {
+	return redis.String(rl.Pop(left))
+}
+
+func (rl *RedisList) PopInt(left bool) (int, error) {
+	return redis.Int(rl.Pop(left))
+}
+
+// PopMulti 从左边取出最多n个元素
+func (rl *RedisList) PopMulti(n int) ([]string, error) {
+	return redis.Strings(rl.Exec("LPOP", n))
+}
+
+func (rl *RedisList) GetIndex(index int) (any, error) {
+	return rl.Exec("LINDEX", index)
+}
+
+func (rl *RedisList) GetString(index int) (string, error) {
+	return redis.String(rl.GetIndex(index))
+}
+
+func (rl *RedisList) SetIndex(index int, value any) (bool, error) {
+	return ReplyBool(rl.Exec("LSET", index, value))
+}
+
+// GetRange 获取一段元素，stop=-1表示到最后
+func (rl *RedisList) GetRange(start, stop int) (any, error) {
+	return rl.Exec("LRANGE", start, stop)
+}
+
+func (rl *RedisList) GetRangeString(start, stop int) ([]string, error) {
+	return redis.Strings(rl.GetRange(start, stop))
+}
+
+func (rl *RedisList) GetRangeInt(start, stop int) ([]int, error) {
+	return redis.Ints(rl.GetRange(start, stop))
+}
+
+func (rl *RedisList) GetAllString() ([]string, error) {
+	return rl.GetRangeString(0, -1)
+}
+
+// Trim 只保留一段元素
+func (rl *RedisList) Trim(start, stop int) (bool, error) {
+	return ReplyBool(rl.Exec("LTRIM", start, stop))
+}
+
+// Remove 删除count个等于value的元素，count=0表示全部
+func (rl *RedisList) Remove(value any, count int) (int, error) {
+	return redis.Int(rl.Exec("LREM", count, value))
+}
@@ -0,0 +1,65 @@
+package redisw
+
+import (
+	"container/list"
+	"sync"
+)
+
+type lruEntry[V any] struct {
+	key   string
+	value V
+}
+
+// lruCache 并发安全的定长缓存，超出容量时淘汰最久未使用的
+type lruCache[V any] struct {
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+	mu    sync.Mutex
+}
+
+func newLruCache[V any](size int) *lruCache[V] {
+	return &lruCache[V]{size: size, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *lruCache[V]) Get(key string) (value V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.ll.MoveToFront(elem)
+		return elem.Value.(*lruEntry[V]).value, true
+	}
+	return
+}
+
+// GetOrAdd 存在时返回已有的，否则添加value
+func (c *lruCache[V]) GetOrAdd(key string, value V) V {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.ll.MoveToFront(elem)
+		return elem.Value.(*lruEntry[V]).value
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry[V]{key: key, value: value})
+	for c.size > 0 && c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[V]).key)
+	}
+	return value
+}
+
+func (c *lruCache[V]) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.ll.Remove(elem)
+		delete(c.items, key)
+	}
+}
+
+func (c *lruCache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
@@ -0,0 +1,214 @@
+package redisw
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	MUTEX_DEFAULT_TTL   = 30 * time.Second       // 锁的默认有效期
+	MUTEX_DEFAULT_RETRY = 100 * time.Millisecond // Lock重试的间隔
+)
+
+// ErrLockNotHeld 锁已过期或者被他人持有
+var ErrLockNotHeld = errors.New("the lock is not held by this owner")
+
+// closedChan 未持有锁时 Lost 返回的通道
+var closedChan = make(chan struct{})
+
+func init() {
+	close(closedChan)
+}
+
+var (
+	// 加锁成功后递增栅栏计数，返回0表示锁已被占用
+	acquireScript = redis.NewScript(2, `
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("INCR", KEYS[2])
+end
+return 0`)
+	releaseScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+	extendScript = redis.NewScript(1, `
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+// Mutex 分布式锁，持有期间在后台自动续期，续期失败时通过 Lost 通知持有者
+type Mutex struct {
+	name   string
+	ttl    time.Duration
+	Retry  time.Duration // Lock重试的间隔
+	owner  string
+	fence  int64
+	lost   chan struct{}
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	*RedisWrapper
+}
+
+// NewMutex 创建分布式锁，ttl<=0时使用默认有效期
+func NewMutex(r *RedisWrapper, name string, ttl time.Duration) *Mutex {
+	if ttl <= 0 {
+		ttl = MUTEX_DEFAULT_TTL
+	}
+	return &Mutex{RedisWrapper: r, name: name, ttl: ttl, Retry: MUTEX_DEFAULT_RETRY}
+}
+
+// GetFenceKey 栅栏计数的键名，在集群中和锁在同一个slot
+func (m *Mutex) GetFenceKey() string {
+	return m.SlotTag(m.name) + ":fence"
+}
+
+// Fence 本次加锁得到的栅栏令牌，单调递增，未持有锁时为0
+// 写入共享资源时带上它，资源方拒绝比已见过更小的令牌
+func (m *Mutex) Fence() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.fence
+}
+
+// Lost 锁丢失或释放后关闭的通道，持有者应该监听它并停止操作共享资源
+// 未持有锁时返回已关闭的通道
+func (m *Mutex) Lost() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.lost == nil {
+		return closedChan
+	}
+	return m.lost
+}
+
+// TryLock 尝试加锁一次，锁已被占用时返回false
+func (m *Mutex) TryLock() (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.owner != "" { // 同一个Mutex不可重入
+		return false, nil
+	}
+	owner, err := newOwnerToken()
+	if err != nil {
+		return false, err
+	}
+	fence, err := redis.Int64(m.Eval(acquireScript, m.name,
+		m.GetFenceKey(), owner, m.ttl.Milliseconds()))
+	if err != nil || fence == 0 {
+		return false, err
+	}
+	m.owner, m.fence, m.lost = owner, fence, make(chan struct{})
+	var ctx context.Context
+	ctx, m.cancel = context.WithCancel(context.Background())
+	go m.renew(ctx, owner)
+	return true, nil
+}
+
+// Lock 加锁，锁被占用时每隔Retry重试，直到ctx结束
+func (m *Mutex) Lock(ctx context.Context) error {
+	for {
+		if ok, err := m.TryLock(); ok || err != nil {
+			return err
+		}
+		timer := time.NewTimer(m.Retry)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Unlock 停止续期并释放锁，锁已过期或被他人持有时返回 ErrLockNotHeld
+func (m *Mutex) Unlock() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.owner == "" {
+		return ErrLockNotHeld
+	}
+	owner := m.owner
+	m.reset()
+	ok, err := redis.Bool(m.Eval(releaseScript, m.name, owner))
+	if err == nil && !ok {
+		err = ErrLockNotHeld
+	}
+	return err
+}
+
+// Extend 延长有效期，锁已过期或被他人持有时返回 ErrLockNotHeld
+func (m *Mutex) Extend(ttl time.Duration) error {
+	m.mu.Lock()
+	owner := m.owner
+	m.mu.Unlock()
+	if owner == "" {
+		return ErrLockNotHeld
+	}
+	return m.extend(owner, ttl)
+}
+
+func (m *Mutex) extend(owner string, ttl time.Duration) error {
+	ok, err := redis.Bool(m.Eval(extendScript, m.name, owner, ttl.Milliseconds()))
+	if err == nil && !ok {
+		err = ErrLockNotHeld
+	}
+	return err
+}
+
+// renew 每隔三分之一有效期续期一次，锁丢失后停止
+// 其他错误可能是网络抖动，下次再试，但超过有效期仍未成功时锁已经过期
+func (m *Mutex) renew(ctx context.Context, owner string) {
+	ticker := time.NewTicker(m.ttl / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := m.extend(owner, m.ttl)
+		if err == nil {
+			renewed = time.Now()
+			continue
+		}
+		if errors.Is(err, ErrLockNotHeld) || time.Since(renewed) >= m.ttl {
+			m.mu.Lock()
+			if m.owner == owner {
+				m.reset()
+			}
+			m.mu.Unlock()
+			return
+		}
+	}
+}
+
+// reset 清除持有状态，调用者需要持有mu
+func (m *Mutex) reset() {
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	if m.lost != nil {
+		close(m.lost)
+		m.lost = nil
+	}
+	m.owner, m.fence = "", 0
+}
+
+func newOwnerToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
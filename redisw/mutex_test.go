@@ -0,0 +1,47 @@
+package redisw_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/azhai/xgen/redisw"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMutex(t *testing.T) {
+	r := GetRedis(t)
+	r.Delete("test:lock")
+	m1 := redisw.NewMutex(r, "test:lock", 3*time.Second)
+	m2 := redisw.NewMutex(r, "test:lock", 3*time.Second)
+	ok, err := m1.TryLock()
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	fence := m1.Fence()
+	ok, err = m2.TryLock()
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	time.Sleep(4 * time.Second) // 自动续期，没有过期
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, m2.Lock(ctx), context.DeadlineExceeded)
+
+	assert.NoError(t, m1.Unlock())
+	assert.ErrorIs(t, m1.Unlock(), redisw.ErrLockNotHeld)
+	assert.NoError(t, m2.Lock(context.Background()))
+	assert.Greater(t, m2.Fence(), fence)
+	assert.NoError(t, m2.Unlock())
+
+	m3 := redisw.NewMutex(r, "test:lock", 300*time.Millisecond)
+	ok, err = m3.TryLock()
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	r.Delete("test:lock") // 被他人释放，续期失败
+	select {
+	case <-m3.Lost():
+	case <-time.After(time.Second):
+		t.Error("the lost lock is not reported")
+	}
+	assert.Zero(t, m3.Fence())
+}
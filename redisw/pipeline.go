@@ -0,0 +1,230 @@
+package redisw
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/gomodule/redigo/redisx"
+)
+
+var (
+	// TxAbortedError WATCH的键被修改，事务没有执行
+	TxAbortedError = errors.New("the transaction was aborted because watched keys changed")
+	// TxUnsupportedError 连接复用不能独占连接，无法执行事务
+	TxUnsupportedError = errors.New("the transaction is not supported by a multiplexed redis connection")
+)
+
+// Replies 管道中各个命令的应答，单个命令的错误以 redis.Error 保存在对应位置
+// Get 和 Err 将其转为 *ServerError 返回
+type Replies []any
+
+// Get 第i个命令的应答
+func (rs Replies) Get(i int) (any, error) {
+	if i < 0 || i >= len(rs) {
+		return nil, fmt.Errorf("the reply index %d is out of range", i)
+	}
+	if err, ok := rs[i].(redis.Error); ok {
+		return nil, ParseServerError(err)
+	}
+	return rs[i], nil
+}
+
+// Err 第一个命令错误
+func (rs Replies) Err() error {
+	for _, reply := range rs {
+		if err, ok := reply.(redis.Error); ok {
+			return ParseServerError(err)
+		}
+	}
+	return nil
+}
+
+func (rs Replies) Bool(i int) (bool, error) {
+	return ReplyBool(rs.Get(i))
+}
+
+func (rs Replies) Int(i int) (int, error) {
+	return redis.Int(rs.Get(i))
+}
+
+func (rs Replies) Int64(i int) (int64, error) {
+	return redis.Int64(rs.Get(i))
+}
+
+func (rs Replies) Float(i int) (float64, error) {
+	return redis.Float64(rs.Get(i))
+}
+
+func (rs Replies) String(i int) (string, error) {
+	return redis.String(rs.Get(i))
+}
+
+func (rs Replies) Strings(i int) ([]string, error) {
+	return redis.Strings(rs.Get(i))
+}
+
+func (rs Replies) StringMap(i int) (map[string]string, error) {
+	return redis.StringMap(rs.Get(i))
+}
+
+type pipeCmd struct {
+	name string
+	args []any
+}
+
+// Pipeline 管道，在同一个连接上积攒命令，一次发送
+type Pipeline struct {
+	conn    redis.Conn
+	cmds    []pipeCmd
+	multi   bool
+	cluster *RedisCluster // 集群中的事务，使用第一个键所在节点的连接
+	*RedisWrapper
+}
+
+// Pipeline 创建管道，用完需要Exec或者Close
+func (r *RedisWrapper) Pipeline() *Pipeline {
+	return &Pipeline{RedisWrapper: r}
+}
+
+// CanTx 是否支持MULTI/EXEC和WATCH，连接复用不支持
+// 集群中事务的所有键必须在同一个slot，可以用 {tag} 保证
+func (r *RedisWrapper) CanTx() bool {
+	_, ok := r.RedisContainer.(*redisx.ConnMux)
+	return !ok
+}
+
+// Tx 在MULTI/EXEC中执行fn积攒的命令，watches中的键被修改时返回 TxAbortedError
+// 连接复用不支持事务，返回 TxUnsupportedError
+func (r *RedisWrapper) Tx(fn func(p *Pipeline) error, watches ...string) (Replies, error) {
+	if !r.CanTx() {
+		return nil, TxUnsupportedError
+	}
+	p := r.Pipeline()
+	defer p.Close()
+	p.cluster, _ = r.RedisContainer.(*RedisCluster)
+	watching := len(watches) > 0
+	if watching {
+		if _, err := p.Do("WATCH", StrToList(watches)...); err != nil {
+			return nil, err
+		}
+	}
+	if err := fn(p); err != nil {
+		if watching {
+			_, _ = p.Do("UNWATCH")
+		}
+		return nil, err
+	}
+	p.multi = true
+	return p.Exec()
+}
+
+// getConn 第一次使用时获取连接，集群中的事务按照这个命令的第一个键选择节点
+func (p *Pipeline) getConn(cmd string, args []any) (redis.Conn, error) {
+	if p.conn != nil {
+		return p.conn, nil
+	}
+	if p.cluster == nil {
+		p.conn = p.RedisWrapper.Get()
+		return p.conn, nil
+	}
+	keys := CommandKeys(cmd, args)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("the command %s has no key to locate the node of redis cluster", cmd)
+	}
+	conn, err := p.cluster.SlotConn(KeySlot(FormatValue(args[keys[0]])))
+	if err != nil {
+		return nil, err
+	}
+	p.conn = conn
+	return conn, nil
+}
+
+// Size 积攒的命令数量
+func (p *Pipeline) Size() int {
+	return len(p.cmds)
+}
+
+// Send 积攒命令，Exec时才发送
+func (p *Pipeline) Send(cmd string, args ...any) *Pipeline {
+	p.cmds = append(p.cmds, pipeCmd{name: cmd, args: p.PrefixArgs(cmd, args)})
+	return p
+}
+
+// Do 在同一个连接上立即执行命令，用于WATCH之后的读取
+func (p *Pipeline) Do(cmd string, args ...any) (any, error) {
+	args = p.PrefixArgs(cmd, args)
+	conn, err := p.getConn(cmd, args)
+	if err != nil {
+		return nil, err
+	}
+	if mrd := p.GetMaxReadDuration(); mrd > 0 {
+		return redis.DoWithTimeout(conn, mrd, cmd, args...)
+	}
+	return conn.Do(cmd, args...)
+}
+
+// Exec 发送所有命令并读取应答，之后关闭连接
+func (p *Pipeline) Exec() (Replies, error) {
+	defer p.Close()
+	if len(p.cmds) == 0 {
+		return Replies{}, nil
+	}
+	conn, err := p.getConn(p.cmds[0].name, p.cmds[0].args)
+	if err != nil {
+		return nil, err
+	}
+	if p.multi {
+		if err = conn.Send("MULTI"); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range p.cmds {
+		if err := conn.Send(c.name, c.args...); err != nil {
+			return nil, err
+		}
+	}
+	if p.multi {
+		values, err := redis.Values(p.Do("EXEC"))
+		if errors.Is(err, redis.ErrNil) {
+			return nil, TxAbortedError
+		} else if p.cluster != nil {
+			p.cluster.checkMoved(err)
+		}
+		return values, err
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	replies := make(Replies, len(p.cmds))
+	mrd := p.GetMaxReadDuration()
+	for i := range p.cmds {
+		var (
+			reply any
+			err   error
+		)
+		if mrd > 0 {
+			reply, err = redis.ReceiveWithTimeout(conn, mrd)
+		} else {
+			reply, err = conn.Receive()
+		}
+		if rerr, ok := err.(redis.Error); ok {
+			reply = rerr
+		} else if err != nil {
+			return replies, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// Close 丢弃没有发送的命令，归还连接
+func (p *Pipeline) Close() error {
+	p.cmds = nil
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
@@ -0,0 +1,33 @@
+package redisw_test
+
+import (
+	"testing"
+
+	"github.com/azhai/xgen/redisw"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineTx(t *testing.T) {
+	r := redisw.NewRedisFake()
+	p := r.Pipeline().Send("SET", "test:p", 5).Send("INCR", "test:p").Send("HGET", "test:p", "x")
+	replies, err := p.Exec()
+	assert.NoError(t, err)
+	n, err := replies.Int(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, n)
+	assert.Error(t, replies.Err()) // WRONGTYPE
+
+	replies, err = r.Tx(func(p *redisw.Pipeline) error {
+		r.SetVal("test:p", 1, 60) // 其他连接修改了WATCH的键
+		p.Send("INCR", "test:p")
+		return nil
+	}, "test:p")
+	assert.ErrorIs(t, err, redisw.TxAbortedError)
+	replies, err = r.Tx(func(p *redisw.Pipeline) error {
+		p.Send("INCR", "test:p").Send("EXPIRE", "test:p", 60)
+		return nil
+	}, "test:p")
+	assert.NoError(t, err)
+	n, _ = replies.Int(0)
+	assert.Equal(t, 2, n)
+}
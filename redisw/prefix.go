@@ -0,0 +1,68 @@
+package redisw
+
+import (
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// WithPrefix 复制一个在当前前缀后追加p的包装器，共用同一个容器
+// 例如 r.WithPrefix("app:").WithPrefix("tenant42:") 的键都以 app:tenant42: 开头
+func (r *RedisWrapper) WithPrefix(p string) *RedisWrapper {
+	dup := *r
+	dup.Prefix = r.Prefix + p
+	return &dup
+}
+
+// AddPrefix 给键加上前缀
+func (r *RedisWrapper) AddPrefix(key string) string {
+	return r.Prefix + key
+}
+
+// StripPrefix 去掉键的前缀，用于SCAN等返回键名的命令
+func (r *RedisWrapper) StripPrefix(key string) string {
+	return strings.TrimPrefix(key, r.Prefix)
+}
+
+// PrefixArgs 按照命令中键的位置加上前缀，其他参数不变，不会修改原来的args
+func (r *RedisWrapper) PrefixArgs(cmd string, args []any) []any {
+	if r.Prefix == "" {
+		return args
+	}
+	keys := CommandKeys(cmd, args)
+	if len(keys) == 0 {
+		return args
+	}
+	result := make([]any, len(args))
+	copy(result, args)
+	for _, i := range keys {
+		result[i] = r.Prefix + FormatValue(args[i])
+	}
+	return result
+}
+
+// escapeGlob 转义通配符中的特殊字符，使前缀按字面匹配
+func escapeGlob(s string) string {
+	var sb strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[]\`, c) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// prefixConn 给连接上的命令加前缀，用于Lua脚本等直接使用连接的地方
+type prefixConn struct {
+	redis.Conn
+	r *RedisWrapper
+}
+
+func (c prefixConn) Do(cmd string, args ...any) (any, error) {
+	return c.Conn.Do(cmd, c.r.PrefixArgs(cmd, args)...)
+}
+
+func (c prefixConn) Send(cmd string, args ...any) error {
+	return c.Conn.Send(cmd, c.r.PrefixArgs(cmd, args)...)
+}
@@ -0,0 +1,51 @@
+package redisw_test
+
+import (
+	"testing"
+
+	"github.com/azhai/xgen/redisw"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakePrefix(t *testing.T) {
+	r := redisw.NewRedisFake()
+	app := r.WithPrefix("app:")
+	tenant := app.WithPrefix("t42:")
+	assert.Equal(t, "app:t42:", tenant.Prefix)
+	assert.Equal(t, "", r.Prefix)
+
+	ok, err := tenant.SetVal("sess:x", "v", 60)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	value, _ := r.GetString("app:t42:sess:x")
+	assert.Equal(t, "v", value)
+	_, err = tenant.Exec("MSET", "k1", "k1", "k2", "k2") // 值不加前缀
+	assert.NoError(t, err)
+	value, _ = r.GetString("app:t42:k1")
+	assert.Equal(t, "k1", value)
+	r.SetVal("other", "x", 60)
+
+	keys, err := tenant.Find("*")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"k1", "k2", "sess:x"}, keys)
+	keys, _ = app.Find("t42:sess:*")
+	assert.Equal(t, []string{"t42:sess:x"}, keys)
+
+	_, err = tenant.Tx(func(p *redisw.Pipeline) error {
+		p.Send("INCR", "cnt").Send("EXPIRE", "cnt", 60)
+		return nil
+	}, "cnt")
+	assert.NoError(t, err)
+	value, _ = r.GetString("app:t42:cnt")
+	assert.Equal(t, "1", value)
+	num, err := tenant.DeleteMatching("k*", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, num)
+	assert.Equal(t, 3, r.GetSize())
+
+	args := tenant.PrefixArgs("EVALSHA", []any{"sha", 1, "lock", "owner"})
+	assert.Equal(t, []any{"sha", 1, "app:t42:lock", "owner"}, args)
+	assert.Equal(t, []int{0, 2, 3}, redisw.CommandKeys("ZUNIONSTORE", []any{"d", 2, "a", "b", "WEIGHTS", 1, 2}))
+	assert.Equal(t, []int{1, 2}, redisw.CommandKeys("BITOP", []any{"AND", "d", "a"}))
+	assert.Empty(t, redisw.CommandKeys("SLOWLOG", []any{"GET"}))
+}
@@ -0,0 +1,220 @@
+package redisw
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/azhai/xgen/dialect"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	SUBSCRIBE_MIN_BACKOFF  = 500 * time.Millisecond // 重连的最短等待
+	SUBSCRIBE_MAX_BACKOFF  = 30 * time.Second       // 重连的最长等待
+	SUBSCRIBE_HEALTH_CHECK = 30 * time.Second       // 发送PING检查连接的间隔
+)
+
+// ErrNoSubscription 没有注册任何频道
+var ErrNoSubscription = errors.New("there is no channel or pattern to subscribe")
+
+// MessageHandler 消息处理函数，在接收协程中依次调用，不要阻塞太久
+type MessageHandler func(channel string, data []byte)
+
+// Publish 发布消息，返回收到消息的订阅者数量
+func (r *RedisWrapper) Publish(channel string, message any) (int, error) {
+	return redis.Int(r.Exec("PUBLISH", channel, message))
+}
+
+// Subscriber 订阅管理，使用独占的连接，断线后自动重连并重新订阅
+type Subscriber struct {
+	dial        func() (redis.Conn, error)
+	channels    map[string][]MessageHandler
+	patterns    map[string][]MessageHandler
+	psc         *redis.PubSubConn
+	mu          sync.Mutex
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	HealthCheck time.Duration
+	OnError     func(err error) // 连接出错时回调，之后会重连
+}
+
+// NewSubscriber 创建订阅管理，dial用于建立独占连接
+func NewSubscriber(dial func() (redis.Conn, error)) *Subscriber {
+	return &Subscriber{
+		dial:        dial,
+		channels:    make(map[string][]MessageHandler),
+		patterns:    make(map[string][]MessageHandler),
+		MinBackoff:  SUBSCRIBE_MIN_BACKOFF,
+		MaxBackoff:  SUBSCRIBE_MAX_BACKOFF,
+		HealthCheck: SUBSCRIBE_HEALTH_CHECK,
+	}
+}
+
+// NewRedisSubscriber 根据配置创建订阅管理，连接复用不能用于订阅
+func NewRedisSubscriber(cfg dialect.ConnConfig, db int) *Subscriber {
+	return NewSubscriber(func() (redis.Conn, error) {
+		return NewRedisConnDb(cfg, db)
+	})
+}
+
+// Subscribe 订阅频道，运行中也可以添加
+func (s *Subscriber) Subscribe(channel string, handler MessageHandler) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.channels[channel] = append(s.channels[channel], handler)
+	if s.psc != nil && len(s.channels[channel]) == 1 {
+		return s.psc.Subscribe(channel)
+	}
+	return nil
+}
+
+// PSubscribe 按照通配符订阅频道，运行中也可以添加
+func (s *Subscriber) PSubscribe(pattern string, handler MessageHandler) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.patterns[pattern] = append(s.patterns[pattern], handler)
+	if s.psc != nil && len(s.patterns[pattern]) == 1 {
+		return s.psc.PSubscribe(pattern)
+	}
+	return nil
+}
+
+// Unsubscribe 取消频道的全部订阅
+func (s *Subscriber) Unsubscribe(channel string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.channels, channel)
+	if s.psc != nil {
+		return s.psc.Unsubscribe(channel)
+	}
+	return nil
+}
+
+// PUnsubscribe 取消通配符的全部订阅
+func (s *Subscriber) PUnsubscribe(pattern string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.patterns, pattern)
+	if s.psc != nil {
+		return s.psc.PUnsubscribe(pattern)
+	}
+	return nil
+}
+
+// Run 接收并分发消息，断线后按照指数退避重连，直到ctx结束
+func (s *Subscriber) Run(ctx context.Context) error {
+	backoff := s.MinBackoff
+	for {
+		connected, err := s.serve(ctx)
+		if ctx.Err() != nil {
+			return nil
+		} else if errors.Is(err, ErrNoSubscription) {
+			return err
+		}
+		if err != nil && s.OnError != nil {
+			s.OnError(err)
+		}
+		if connected { // 连上过，从头计算等待时间
+			backoff = s.MinBackoff
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+	}
+}
+
+// connect 建立连接并订阅已注册的频道
+func (s *Subscriber) connect() (*redis.PubSubConn, error) {
+	conn, err := s.dial()
+	if err != nil {
+		return nil, err
+	}
+	psc := &redis.PubSubConn{Conn: conn}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var channels, patterns []any
+	for ch := range s.channels {
+		channels = append(channels, ch)
+	}
+	for pat := range s.patterns {
+		patterns = append(patterns, pat)
+	}
+	if len(channels) == 0 && len(patterns) == 0 {
+		err = ErrNoSubscription
+	}
+	if err == nil && len(channels) > 0 {
+		err = psc.Subscribe(channels...)
+	}
+	if err == nil && len(patterns) > 0 {
+		err = psc.PSubscribe(patterns...)
+	}
+	if err != nil {
+		_ = psc.Close()
+		return nil, err
+	}
+	s.psc = psc
+	return psc, nil
+}
+
+func (s *Subscriber) serve(ctx context.Context) (bool, error) {
+	psc, err := s.connect()
+	if err != nil {
+		return false, err
+	}
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		s.mu.Lock()
+		s.psc = nil
+		s.mu.Unlock()
+		_ = psc.Close()
+	}()
+	go func() { // 定时PING，ctx结束时关闭连接让Receive返回
+		ticker := time.NewTicker(s.HealthCheck)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				_ = psc.Close()
+				return
+			case <-ticker.C:
+				s.mu.Lock()
+				_ = psc.Ping("")
+				s.mu.Unlock()
+			}
+		}
+	}()
+	for {
+		switch v := psc.ReceiveWithTimeout(s.HealthCheck * 2).(type) {
+		case redis.Message:
+			s.dispatch(v)
+		case error:
+			return true, v
+		}
+	}
+}
+
+func (s *Subscriber) dispatch(msg redis.Message) {
+	s.mu.Lock()
+	var handlers []MessageHandler
+	if msg.Pattern != "" {
+		handlers = s.patterns[msg.Pattern]
+	} else {
+		handlers = s.channels[msg.Channel]
+	}
+	s.mu.Unlock()
+	for _, handler := range handlers {
+		handler(msg.Channel, msg.Data)
+	}
+}
@@ -0,0 +1,33 @@
+package redisw_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/azhai/xgen/redisw"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscriber(t *testing.T) {
+	r := GetRedis(t)
+	sub := redisw.NewRedisSubscriber(cfg, -1)
+	received := make(chan string, 4)
+	sub.Subscribe("test:invalidate", func(channel string, data []byte) {
+		received <- string(data)
+	})
+	sub.PSubscribe("test:events:*", func(channel string, data []byte) {
+		received <- channel
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() { stopped <- sub.Run(ctx) }()
+	time.Sleep(200 * time.Millisecond)
+
+	r.Publish("test:invalidate", "user:1")
+	r.Publish("test:events:login", "x")
+	assert.Equal(t, "user:1", <-received)
+	assert.Equal(t, "test:events:login", <-received)
+	cancel()
+	assert.NoError(t, <-stopped)
+}
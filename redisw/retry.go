@@ -0,0 +1,194 @@
+package redisw
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/azhai/xgen/dialect"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	REDIS_RETRY_BACKOFF     = 50 * time.Millisecond // 第一次重试前的等待
+	REDIS_RETRY_MAX_BACKOFF = 2 * time.Second       // 重试等待的上限
+)
+
+// ServerError redis服务端返回的错误，Kind是错误信息的第一个单词
+type ServerError struct {
+	Kind    string
+	Message string
+}
+
+// 常见的服务端错误，用 errors.Is 判断
+var (
+	ErrGeneric   = &ServerError{Kind: "ERR"}
+	ErrWrongType = &ServerError{Kind: "WRONGTYPE"}
+	ErrNoScript  = &ServerError{Kind: "NOSCRIPT"}
+	ErrBusy      = &ServerError{Kind: "BUSY"}
+	ErrLoading   = &ServerError{Kind: "LOADING"}
+	ErrReadOnly  = &ServerError{Kind: "READONLY"}
+	ErrNoAuth    = &ServerError{Kind: "NOAUTH"}
+	ErrOOM       = &ServerError{Kind: "OOM"}
+	ErrExecAbort = &ServerError{Kind: "EXECABORT"}
+	ErrMoved     = &ServerError{Kind: "MOVED"}
+	ErrAsk       = &ServerError{Kind: "ASK"}
+)
+
+func (e *ServerError) Error() string {
+	if e.Message == "" {
+		return e.Kind
+	}
+	return e.Message
+}
+
+// Is 类型相同即可，不比较具体信息
+func (e *ServerError) Is(target error) bool {
+	t, ok := target.(*ServerError)
+	return ok && t.Kind == e.Kind
+}
+
+// Unwrap 仍然可以用 errors.As 取得 redis.Error
+func (e *ServerError) Unwrap() error {
+	return redis.Error(e.Message)
+}
+
+// ParseServerError 将 redis.Error 转为 *ServerError ，其他错误原样返回
+func ParseServerError(err error) error {
+	var rerr redis.Error
+	if err == nil || !errors.As(err, &rerr) {
+		return err
+	}
+	var serr *ServerError
+	if errors.As(err, &serr) {
+		return err
+	}
+	msg := string(rerr)
+	kind, _, _ := strings.Cut(msg, " ")
+	return &ServerError{Kind: kind, Message: msg}
+}
+
+// IsRetryable 只有网络错误和超时可以重试，服务端错误和取消都不重试
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var rerr redis.Error
+	if errors.As(err, &rerr) {
+		return false
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) {
+		return true
+	}
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE)
+}
+
+// IdempotentCommands 命令发出后出现网络错误时仍可重试的命令，重复执行不改变结果
+// 其他命令只在连接没有建立时重试，避免INCR、LPUSH、EVAL等执行两次，可以在初始化时增删
+var IdempotentCommands = map[string]bool{
+	"PING": true, "INFO": true, "TIME": true, "DBSIZE": true, "KEYS": true, "SCAN": true,
+	"GET": true, "MGET": true, "STRLEN": true, "GETRANGE": true,
+	"EXISTS": true, "TYPE": true, "TTL": true, "PTTL": true,
+	"HGET": true, "HMGET": true, "HGETALL": true, "HKEYS": true, "HVALS": true,
+	"HLEN": true, "HEXISTS": true, "HSCAN": true,
+	"LLEN": true, "LRANGE": true, "LINDEX": true,
+	"SMEMBERS": true, "SISMEMBER": true, "SMISMEMBER": true, "SCARD": true,
+	"SINTER": true, "SUNION": true, "SDIFF": true, "SSCAN": true,
+	"ZSCORE": true, "ZCARD": true, "ZCOUNT": true, "ZRANK": true, "ZREVRANK": true,
+	"ZRANGE": true, "ZREVRANGE": true, "ZRANGEBYSCORE": true, "ZSCAN": true,
+	"XLEN": true, "XRANGE": true, "XREVRANGE": true, "XPENDING": true,
+	"SETEX": true, "PSETEX": true, "MSET": true, "HSET": true, "HMSET": true,
+	"EXPIRE": true, "PEXPIRE": true, "PERSIST": true,
+}
+
+// IsDialError 连接没有建立，命令还没有发出，任何命令都可以重试
+func IsDialError(err error) bool {
+	var operr *net.OpError
+	if errors.As(err, &operr) && operr.Op == "dial" {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// canRetry 可以重试的错误中，只有连接失败或者幂等命令才重试
+func canRetry(cmd string, err error) bool {
+	if !IsRetryable(err) {
+		return false
+	}
+	return IsDialError(err) || IdempotentCommands[strings.ToUpper(cmd)]
+}
+
+// RetryBackoff 第attempt次重试前的等待，指数增长并加上随机抖动
+func RetryBackoff(attempt int) time.Duration {
+	d := REDIS_RETRY_MAX_BACKOFF
+	if attempt < 16 {
+		d = min(REDIS_RETRY_BACKOFF<<attempt, REDIS_RETRY_MAX_BACKOFF)
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// ExecContext 执行命令，每次用完归还连接，总共最多 RetryTimes 次
+// 连接失败时等待后重试，已经发出命令后的网络错误只有 IdempotentCommands 重试
+// 服务端错误以 *ServerError 返回，ctx取消时立即停止
+func (r *RedisWrapper) ExecContext(ctx context.Context, cmd string, args ...any) (reply any, err error) {
+	args = r.PrefixArgs(cmd, args)
+	if dialect.HasCommandHooks() {
+		evt := &dialect.CommandEvent{Driver: "redis", ConnKey: r.ConnKey,
+			Command: strings.ToUpper(cmd), Summary: SummarizeArgs(cmd, args)}
+		var finish func(err error)
+		ctx, finish = dialect.StartCommand(ctx, evt)
+		defer func() { finish(err) }()
+	}
+	for i := 0; ; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		reply, err := r.execOnce(ctx, cmd, args)
+		if err == nil || i+1 >= r.RetryTimes || !canRetry(cmd, err) {
+			return reply, ParseServerError(err)
+		}
+		timer := time.NewTimer(RetryBackoff(i))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// execOnce 执行一次，超时取 MaxReadTime 和ctx截止时间中较早的
+func (r *RedisWrapper) execOnce(ctx context.Context, cmd string, args []any) (any, error) {
+	var conn redis.Conn
+	if pool, ok := r.RedisContainer.(*redis.Pool); ok {
+		var err error
+		if conn, err = pool.GetContext(ctx); err != nil {
+			return nil, err
+		}
+	} else {
+		conn = r.Get()
+	}
+	defer conn.Close()
+	timeout := r.GetMaxReadDuration()
+	if deadline, ok := ctx.Deadline(); ok {
+		left := time.Until(deadline)
+		if left <= 0 {
+			return nil, context.DeadlineExceeded
+		} else if timeout == 0 || left < timeout {
+			timeout = left
+		}
+	}
+	if _, ok := conn.(redis.ConnWithTimeout); ok && timeout > 0 {
+		return redis.DoWithTimeout(conn, timeout, cmd, args...)
+	}
+	return conn.Do(cmd, args...)
+}
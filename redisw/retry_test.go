@@ -0,0 +1,84 @@
+package redisw_test
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/azhai/xgen/redisw"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyConn 前几次返回网络错误，op为dial时表示连接失败
+type flakyConn struct {
+	redis.Conn
+	op           string
+	fails, calls *int
+}
+
+func (c flakyConn) Do(cmd string, args ...any) (any, error) {
+	if *c.calls++; *c.calls <= *c.fails {
+		return nil, &net.OpError{Op: c.op, Net: "tcp", Err: syscall.ECONNRESET}
+	}
+	return "PONG", nil
+}
+
+func (c flakyConn) Close() error { return nil }
+
+type flakyContainer struct {
+	op           string
+	fails, calls int
+}
+
+func (f *flakyContainer) Get() redis.Conn {
+	return flakyConn{op: f.op, fails: &f.fails, calls: &f.calls}
+}
+
+func (f *flakyContainer) Close() error { return nil }
+
+func TestFakeRetry(t *testing.T) {
+	r := redisw.NewRedisFake()
+	r.SetVal("test:a", "x", 60)
+	_, err := r.ExecContext(context.Background(), "INCR", "test:a")
+	assert.ErrorIs(t, err, redisw.ErrGeneric)
+	_, err = r.Exec("LPUSH", "test:a", 1)
+	assert.ErrorIs(t, err, redisw.ErrWrongType)
+	var rerr redis.Error
+	assert.ErrorAs(t, err, &rerr)
+	assert.False(t, redisw.IsRetryable(err))
+
+	flaky := &flakyContainer{op: "read", fails: 2}
+	r.RedisContainer = flaky
+	reply, err := r.Exec("PING")
+	assert.NoError(t, err)
+	assert.Equal(t, "PONG", reply)
+	assert.Equal(t, 3, flaky.calls)
+
+	flaky.fails, flaky.calls = 10, 0
+	_, err = r.Exec("PING")
+	assert.True(t, redisw.IsRetryable(err))
+	assert.Equal(t, r.RetryTimes, flaky.calls)
+
+	// 命令已经发出，不是幂等的命令不重试
+	flaky.fails, flaky.calls = 2, 0
+	_, err = r.Exec("INCR", "test:a")
+	assert.Error(t, err)
+	assert.Equal(t, 1, flaky.calls)
+	flaky.op, flaky.calls = "dial", 0 // 连接失败时都可以重试
+	_, err = r.Exec("INCR", "test:a")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, flaky.calls)
+	assert.True(t, redisw.IsDialError(&net.OpError{Op: "dial", Err: syscall.ETIMEDOUT}))
+
+	flaky.calls = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = r.ExecContext(ctx, "PING")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 0, flaky.calls)
+	for i := 0; i < 20; i++ {
+		assert.LessOrEqual(t, redisw.RetryBackoff(i), redisw.REDIS_RETRY_MAX_BACKOFF)
+	}
+}
@@ -0,0 +1,210 @@
+package redisw
+
+import (
+	"iter"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const SCAN_DEFAULT_BATCH = 100 // DeleteMatching 每批删除的数量
+
+// ScanOptions SCAN系列命令的参数
+type ScanOptions struct {
+	Match string // 通配符，为空不过滤
+	Count int    // 每批数量的提示，服务端不保证
+	Type  string // 键的类型，只对SCAN有效
+}
+
+// Scanner 游标迭代器，不会像KEYS那样阻塞服务端
+// 迭代期间增删的键可能被漏掉或者重复返回，集群中SCAN依次遍历每个主节点
+type Scanner struct {
+	cmd   string
+	key   string // 为空时是SCAN
+	pairs bool   // HSCAN和ZSCAN成对返回
+	opts  ScanOptions
+	err   error
+	*RedisWrapper
+}
+
+// Scan 遍历当前db的键，有前缀时只遍历前缀下的键，返回的键去掉了前缀
+func (r *RedisWrapper) Scan(opts ScanOptions) *Scanner {
+	return &Scanner{RedisWrapper: r, cmd: "SCAN", opts: opts}
+}
+
+// HScan 遍历哈希表的字段和值
+func (r *RedisWrapper) HScan(key string, opts ScanOptions) *Scanner {
+	return &Scanner{RedisWrapper: r, cmd: "HSCAN", key: key, pairs: true, opts: opts}
+}
+
+// SScan 遍历集合的成员
+func (r *RedisWrapper) SScan(key string, opts ScanOptions) *Scanner {
+	return &Scanner{RedisWrapper: r, cmd: "SSCAN", key: key, opts: opts}
+}
+
+// ZScan 遍历有序集合的成员和分数
+func (r *RedisWrapper) ZScan(key string, opts ScanOptions) *Scanner {
+	return &Scanner{RedisWrapper: r, cmd: "ZSCAN", key: key, pairs: true, opts: opts}
+}
+
+func (rh *RedisHash) Scan(opts ScanOptions) *Scanner {
+	return rh.RedisWrapper.HScan(rh.name, opts)
+}
+
+func (rs *RedisSet) Scan(opts ScanOptions) *Scanner {
+	return rs.RedisWrapper.SScan(rs.name, opts)
+}
+
+func (rz *RedisZSet) Scan(opts ScanOptions) *Scanner {
+	return rz.RedisWrapper.ZScan(rz.name, opts)
+}
+
+func (s *Scanner) args(cursor string) []any {
+	var args []any
+	if s.key != "" {
+		args = append(args, s.key)
+	}
+	args = append(args, cursor)
+	if match := s.opts.Match; s.key == "" && s.Prefix != "" {
+		if match == "" {
+			match = "*"
+		}
+		args = append(args, "MATCH", escapeGlob(s.Prefix)+match)
+	} else if match != "" {
+		args = append(args, "MATCH", match)
+	}
+	if s.opts.Count > 0 {
+		args = append(args, "COUNT", s.opts.Count)
+	}
+	if s.opts.Type != "" && s.key == "" {
+		args = append(args, "TYPE", s.opts.Type)
+	}
+	return args
+}
+
+// next 读取一批，游标为0时结束
+func (s *Scanner) next(do func(args ...any) (any, error), cursor string) (string, []string, error) {
+	values, err := redis.Values(do(s.args(cursor)...))
+	if err != nil {
+		return "", nil, err
+	}
+	var items []string
+	if _, err = redis.Scan(values, &cursor, &items); err != nil {
+		return "", nil, err
+	}
+	if s.key == "" && s.Prefix != "" {
+		for i, item := range items {
+			items[i] = s.StripPrefix(item)
+		}
+	}
+	return cursor, items, nil
+}
+
+// EachBatch 逐批回调，HSCAN和ZSCAN的一批中键和值交替出现，fn出错时停止
+func (s *Scanner) EachBatch(fn func(items []string) error) error {
+	cluster, ok := s.RedisContainer.(*RedisCluster)
+	if !ok || s.key != "" {
+		return s.eachBatch(func(args ...any) (any, error) {
+			return s.Exec(s.cmd, args...)
+		}, fn)
+	}
+	masters, err := cluster.Masters()
+	if err != nil {
+		return err
+	}
+	for _, addr := range masters {
+		conn := cluster.NodeConn(addr)
+		err = s.eachBatch(func(args ...any) (any, error) {
+			return conn.Do(s.cmd, args...)
+		}, fn)
+		conn.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Scanner) eachBatch(do func(args ...any) (any, error), fn func(items []string) error) error {
+	cursor := "0"
+	for {
+		next, items, err := s.next(do, cursor)
+		if err != nil {
+			return err
+		}
+		if len(items) > 0 {
+			if err = fn(items); err != nil {
+				return err
+			}
+		}
+		if cursor = next; cursor == "0" {
+			return nil
+		}
+	}
+}
+
+// Each 逐个回调，SCAN和SSCAN的value为空，fn返回false时停止
+func (s *Scanner) Each(fn func(key, value string) bool) error {
+	stop := redis.Error("stop")
+	err := s.EachBatch(func(items []string) error {
+		for i := 0; i < len(items); i++ {
+			key, value := items[i], ""
+			if s.pairs && i+1 < len(items) {
+				i++
+				value = items[i]
+			}
+			if !fn(key, value) {
+				return stop
+			}
+		}
+		return nil
+	})
+	if err == stop {
+		return nil
+	}
+	return err
+}
+
+// All 迭代键和值，结束后用 Err 检查错误
+func (s *Scanner) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		s.err = s.Each(yield)
+	}
+}
+
+// Keys 只迭代键或成员，结束后用 Err 检查错误
+func (s *Scanner) Keys() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		s.err = s.Each(func(key, _ string) bool {
+			return yield(key)
+		})
+	}
+}
+
+// Err 最近一次迭代的错误
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Collect 读取全部键或成员
+func (s *Scanner) Collect() (keys []string, err error) {
+	err = s.Each(func(key, _ string) bool {
+		keys = append(keys, key)
+		return true
+	})
+	return
+}
+
+// DeleteMatching 分批删除匹配的键，返回删除的数量
+func (r *RedisWrapper) DeleteMatching(pattern string, batch int) (int, error) {
+	if batch <= 0 {
+		batch = SCAN_DEFAULT_BATCH
+	}
+	var total int
+	scanner := r.Scan(ScanOptions{Match: pattern, Count: batch})
+	err := scanner.EachBatch(func(items []string) error {
+		num, err := redis.Int(r.Exec("UNLINK", StrToList(items)...))
+		total += num
+		return err
+	})
+	return total, err
+}
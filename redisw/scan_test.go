@@ -0,0 +1,40 @@
+package redisw_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/azhai/xgen/redisw"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScan(t *testing.T) {
+	r := redisw.NewRedisFake()
+	for i := 0; i < 25; i++ {
+		r.SetVal(fmt.Sprintf("test:scan:%d", i), i, 60)
+	}
+	keys, err := r.Find("test:scan:*")
+	assert.NoError(t, err)
+	assert.Len(t, keys, 25)
+
+	count, scanner := 0, r.Scan(redisw.ScanOptions{Match: "test:scan:*", Count: 10, Type: "string"})
+	for range scanner.Keys() {
+		if count++; count >= 5 {
+			break
+		}
+	}
+	assert.NoError(t, scanner.Err())
+	assert.Equal(t, 5, count)
+
+	rh := redisw.NewRedisHash(r, "test:scan", 60)
+	rh.SaveMap(redisw.Map{"a": 1, "b": 2}, false)
+	data := make(map[string]string)
+	for field, value := range rh.Scan(redisw.ScanOptions{}).All() {
+		data[field] = value
+	}
+	assert.Equal(t, map[string]string{"a": "1", "b": "2"}, data)
+
+	num, err := r.DeleteMatching("test:scan:*", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 25, num)
+}
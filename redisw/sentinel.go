@@ -0,0 +1,206 @@
+package redisw
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/azhai/xgen/dialect"
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	REDIS_SENTINEL_TIMEOUT = time.Second     // 连接和询问哨兵的超时
+	REDIS_SENTINEL_REFRESH = 3 * time.Second // 主库地址的缓存时长，也是发现切换的最长延迟
+	REDIS_SENTINEL_IDLE    = time.Minute     // 空闲超过这个时长的连接，借出时检查是否还连着主库
+)
+
+// Sentinel 通过哨兵找到当前的主库
+type Sentinel struct {
+	addrs      []string
+	masterName string
+	password   string // 可以是密钥引用
+	master     string
+	checkedAt  time.Time
+	mu         sync.Mutex
+}
+
+// NewSentinel 根据 conn "redis" 中的 master_name 和 sentinels 创建
+func NewSentinel(cfg dialect.ConnConfig) *Sentinel {
+	s := &Sentinel{}
+	if dia, ok := cfg.LoadDialect().(*dialect.Redis); ok {
+		s.addrs = append(s.addrs, dia.Sentinels...)
+		s.masterName, s.password = dia.MasterName, dia.SentinelPassword
+	}
+	return s
+}
+
+func (s *Sentinel) dialOptions() ([]redis.DialOption, error) {
+	opts := []redis.DialOption{
+		redis.DialConnectTimeout(REDIS_SENTINEL_TIMEOUT),
+		redis.DialReadTimeout(REDIS_SENTINEL_TIMEOUT),
+		redis.DialWriteTimeout(REDIS_SENTINEL_TIMEOUT),
+	}
+	if s.password != "" {
+		password, err := dialect.ResolveSecret(s.password)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, redis.DialPassword(password))
+	}
+	return opts, nil
+}
+
+// MasterAddr 依次询问哨兵，回答了的哨兵调到最前面
+func (s *Sentinel) MasterAddr() (string, error) {
+	opts, err := s.dialOptions()
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	addrs := append([]string{}, s.addrs...)
+	s.mu.Unlock()
+	err = fmt.Errorf("there is no sentinel for master %s", s.masterName)
+	for i, addr := range addrs {
+		var master string
+		if master, err = s.askMaster(addr, opts); err != nil {
+			continue
+		}
+		s.mu.Lock()
+		if i > 0 && i < len(s.addrs) && s.addrs[i] == addr {
+			s.addrs[0], s.addrs[i] = s.addrs[i], s.addrs[0]
+		}
+		s.master, s.checkedAt = master, time.Now()
+		s.mu.Unlock()
+		return master, nil
+	}
+	return "", err
+}
+
+func (s *Sentinel) askMaster(addr string, opts []redis.DialOption) (string, error) {
+	conn, err := redis.Dial("tcp", addr, opts...)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	res, err := redis.Strings(conn.Do("SENTINEL", "get-master-addr-by-name", s.masterName))
+	if errors.Is(err, redis.ErrNil) {
+		return "", fmt.Errorf("the sentinel %s does not know master %s", addr, s.masterName)
+	} else if err != nil {
+		return "", err
+	} else if len(res) < 2 {
+		return "", fmt.Errorf("the sentinel %s returns a bad address", addr)
+	}
+	return net.JoinHostPort(res[0], res[1]), nil
+}
+
+// CachedMasterAddr 缓存一小段时间的主库地址
+func (s *Sentinel) CachedMasterAddr() (string, error) {
+	s.mu.Lock()
+	master, fresh := s.master, time.Since(s.checkedAt) < REDIS_SENTINEL_REFRESH
+	s.mu.Unlock()
+	if master != "" && fresh {
+		return master, nil
+	}
+	return s.MasterAddr()
+}
+
+// DialMaster 连接当前的主库，并确认它的角色，防止切换期间连到旧主库
+func (s *Sentinel) DialMaster(opts ...redis.DialOption) (redis.Conn, error) {
+	addr, err := s.MasterAddr()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := redis.Dial("tcp", addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err = TestRole(conn, "master"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &sentinelConn{Conn: conn, addr: addr}, nil
+}
+
+// TestRole 检查连接的角色 master slave sentinel
+func TestRole(conn redis.Conn, expected string) error {
+	values, err := redis.Values(conn.Do("ROLE"))
+	if err != nil {
+		return err
+	} else if len(values) == 0 {
+		return errors.New("the reply of ROLE is empty")
+	}
+	role, err := redis.String(values[0], nil)
+	if err == nil && role != expected {
+		err = fmt.Errorf("the role of redis is %s, not %s", role, expected)
+	}
+	return err
+}
+
+// sentinelConn 记住连接的主库地址，主库切换后丢弃
+// 旧主库降级后写入返回READONLY，之后 Err 不为空，连接池不再复用
+type sentinelConn struct {
+	redis.Conn
+	addr     string
+	readonly bool
+}
+
+func (c *sentinelConn) Err() error {
+	if c.readonly {
+		return fmt.Errorf("the redis %s is no longer the master", c.addr)
+	}
+	return c.Conn.Err()
+}
+
+func (c *sentinelConn) Do(cmd string, args ...any) (any, error) {
+	return c.check(c.Conn.Do(cmd, args...))
+}
+
+func (c *sentinelConn) DoWithTimeout(timeout time.Duration, cmd string, args ...any) (any, error) {
+	return c.check(redis.DoWithTimeout(c.Conn, timeout, cmd, args...))
+}
+
+func (c *sentinelConn) check(reply any, err error) (any, error) {
+	if errors.Is(ParseServerError(err), ErrReadOnly) {
+		c.readonly = true
+	}
+	return reply, err
+}
+
+func (c *sentinelConn) ReceiveWithTimeout(timeout time.Duration) (any, error) {
+	return redis.ReceiveWithTimeout(c.Conn, timeout)
+}
+
+// NewRedisSentinelPool 建立主库的连接池，主库切换后丢弃旧连接
+func NewRedisSentinelPool(cfg dialect.ConnConfig, maxIdle int) *RedisWrapper {
+	r := NewRedisWrapper()
+	r.ConnKey = cfg.Key
+	if maxIdle >= 0 {
+		r.MaxIdleConn = maxIdle
+	}
+	s := NewSentinel(cfg)
+	timeout := time.Second * time.Duration(r.MaxIdleTime)
+	r.RedisContainer = &redis.Pool{
+		MaxIdle: r.MaxIdleConn, IdleTimeout: timeout,
+		Dial: func() (redis.Conn, error) {
+			opts, err := RedisDialOptions(cfg, -1)
+			if err != nil {
+				return nil, err
+			}
+			return s.DialMaster(opts...)
+		},
+		TestOnBorrow: func(conn redis.Conn, t time.Time) error {
+			if time.Since(t) < REDIS_SENTINEL_IDLE {
+				return nil // 最近用过的连接不检查，切换后靠READONLY错误丢弃
+			}
+			master, err := s.CachedMasterAddr()
+			if sc, ok := conn.(*sentinelConn); ok && err == nil && sc.addr != master {
+				return fmt.Errorf("the master has moved from %s to %s", sc.addr, master)
+			}
+			return nil // 哨兵暂时不可用时继续使用旧连接
+		},
+	}
+	return r
+}
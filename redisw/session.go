@@ -1,7 +1,9 @@
 package redisw
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/gomodule/redigo/redis"
@@ -12,10 +14,12 @@ const (
 	SESS_NOT_EXISTS = -2 // 不存在
 	SESS_ERR_REDIS  = -3 // redis错误
 
-	SESS_PREFIX     = "sess" // 会话缓存前缀
-	SESS_LIST_SEP   = ";"    // 角色名之间的分隔符
-	SESS_TOKEN_KEY  = "_token_"
-	SESS_ONLINE_KEY = "onlines" // 在线用户
+	SESS_PREFIX       = "sess" // 会话缓存前缀
+	SESS_LIST_SEP     = ";"    // 角色名之间的分隔符
+	SESS_TOKEN_KEY    = "_token_"
+	SESS_FLASH_PREFIX = "flash"   // 临时消息前缀
+	SESS_ONLINE_KEY   = "onlines" // 在线用户，每个用户一个集合
+	SESS_CACHE_SIZE   = 10000     // 本地缓存的会话数量
 
 	MAX_TIMEOUT = 86400 * 30 // 接近无限时间
 )
@@ -28,46 +32,261 @@ func SessListSplit(data string) []string {
 	return strings.Split(data, SESS_LIST_SEP)
 }
 
+// SessionRegistry 会话管理，本地缓存最近使用的会话，可以并发使用
+// 会话的读写通过 RedisSessionStore ，这里另外管理用户的多个会话
+// 原来的 Onlines 哈希每个用户只能记录一个会话，已改为每个用户一个集合，见 ListSessions
 type SessionRegistry struct {
-	sessions map[string]*Session
-	Onlines  *RedisHash
+	sessions *lruCache[*Session]
+	store    *RedisSessionStore
 	*RedisWrapper
 }
 
 func NewRegistry(w *RedisWrapper) *SessionRegistry {
-	return &SessionRegistry{
-		sessions:     make(map[string]*Session),
-		Onlines:      NewRedisHash(w, SESS_ONLINE_KEY, MAX_TIMEOUT),
-		RedisWrapper: w,
-	}
+	return NewRegistrySize(w, SESS_CACHE_SIZE)
+}
+
+// NewRegistrySize 指定本地缓存的会话数量，size<=0表示不限
+func NewRegistrySize(w *RedisWrapper, size int) *SessionRegistry {
+	return &SessionRegistry{sessions: newLruCache[*Session](size),
+		store: NewRedisSessionStore(w), RedisWrapper: w}
 }
 
 func (sr SessionRegistry) GetKey(token string) string {
 	return fmt.Sprintf("%s:%s", SESS_PREFIX, token)
 }
 
+// GetUserKey 用户在线会话集合的键名
+func (sr SessionRegistry) GetUserKey(uid string) string {
+	return fmt.Sprintf("%s:%s", SESS_ONLINE_KEY, uid)
+}
+
 func (sr *SessionRegistry) GetSession(token string, timeout int) *Session {
 	key := sr.GetKey(token)
-	if sess, ok := sr.sessions[key]; ok && sess != nil {
+	if sess, ok := sr.sessions.Get(key); ok && sess != nil {
 		return sess
 	}
 	sess := NewSession(sr, key, timeout)
 	if _, err := sess.SetVal(SESS_TOKEN_KEY, token); err == nil {
-		sr.sessions[key] = sess
+		sess = sr.sessions.GetOrAdd(key, sess)
 	}
 	return sess
 }
 
+// DelSession 删除会话和临时消息，用户集合中残留的token会在 ListSessions 时清理
 func (sr *SessionRegistry) DelSession(token string) bool {
 	key := sr.GetKey(token)
-	if sess, ok := sr.sessions[key]; ok {
-		succ, err := sess.DeleteAll()
-		if succ && err == nil {
-			delete(sr.sessions, key)
-			return true
+	uid, _ := redis.String(sr.Exec("HGET", key, "uid"))
+	p := sr.Pipeline().Send("DEL", key, sr.GetFlashKey(key), legacyFlashKey(key))
+	if uid != "" {
+		p.Send("SREM", sr.GetUserKey(uid), token)
+	}
+	replies, err := p.Exec()
+	sr.sessions.Remove(key)
+	if err == nil {
+		err = replies.Err()
+	}
+	deleted, _ := replies.Int(0)
+	return err == nil && deleted > 0
+}
+
+// RotateSession 更换会话的token，字段、临时消息和过期时间都保留，旧token不再可用
+// 会话不存在时返回 redis.ErrNil
+func (sr *SessionRegistry) RotateSession(oldToken, newToken string, timeout int) (*Session, error) {
+	oldKey, newKey := sr.GetKey(oldToken), sr.GetKey(newToken)
+	moves := [][2]string{
+		{oldKey, newKey},
+		{sr.GetFlashKey(oldKey), sr.GetFlashKey(newKey)},
+		{legacyFlashKey(oldKey), legacyFlashKey(newKey)},
+	}
+	move := renameKeys
+	if !sr.useTx() {
+		move = copyKeys
+	}
+	replies, err := sr.update(func(p *Pipeline) error {
+		uid, err := redis.String(p.Do("HGET", oldKey, "uid"))
+		if err != nil && !errors.Is(err, redis.ErrNil) {
+			return err
+		}
+		exists, err := redis.Int(p.Do("EXISTS", oldKey))
+		if err != nil {
+			return err
+		} else if exists == 0 {
+			return redis.ErrNil
+		}
+		if err = move(p, moves); err != nil {
+			return err
+		}
+		p.Send("HSET", newKey, SESS_TOKEN_KEY, newToken)
+		if uid != "" {
+			userKey := sr.GetUserKey(uid)
+			p.Send("SREM", userKey, oldToken).Send("SADD", userKey, newToken)
+		}
+		return nil
+	}, oldKey, moves[1][0], moves[2][0])
+	sr.sessions.Remove(oldKey)
+	if err == nil {
+		err = replies.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	sess := NewSession(sr, newKey, timeout)
+	return sr.sessions.GetOrAdd(newKey, sess), nil
+}
+
+// renameKeys 在事务中逐个改名，不存在的键跳过
+func renameKeys(p *Pipeline, moves [][2]string) error {
+	for _, mv := range moves {
+		exists, err := redis.Int(p.Do("EXISTS", mv[0]))
+		if err != nil {
+			return err
+		} else if exists > 0 {
+			p.Send("RENAME", mv[0], mv[1])
+		}
+	}
+	return nil
+}
+
+// copyKeys 集群中不同slot的键不能改名，复制哈希或列表和剩余时间后删除原来的键
+func copyKeys(p *Pipeline, moves [][2]string) error {
+	for _, mv := range moves {
+		src, dst := mv[0], mv[1]
+		kind, err := redis.String(p.Do("TYPE", src))
+		if err != nil {
+			return err
+		}
+		var args []any
+		switch kind {
+		case "none":
+			continue
+		case "hash":
+			args, err = redis.Values(p.Do("HGETALL", src))
+		case "list":
+			args, err = redis.Values(p.Do("LRANGE", src, 0, -1))
+		default:
+			return fmt.Errorf("the key %s of type %s can not be copied", src, kind)
+		}
+		if err != nil {
+			return err
+		}
+		pttl, err := redis.Int64(p.Do("PTTL", src))
+		if err != nil {
+			return err
+		}
+		cmd := "HSET"
+		if kind == "list" {
+			cmd = "RPUSH"
 		}
+		p.Send("DEL", dst).Send(cmd, append([]any{dst}, args...)...)
+		if pttl > 0 {
+			p.Send("PEXPIRE", dst, pttl)
+		}
+		p.Send("DEL", src)
+	}
+	return nil
+}
+
+// ListSessions 用户所有在线会话的token，顺便清理已过期的
+func (sr *SessionRegistry) ListSessions(uid string) ([]string, error) {
+	userKey := sr.GetUserKey(uid)
+	tokens, err := redis.Strings(sr.Exec("SMEMBERS", userKey))
+	if err != nil || len(tokens) == 0 {
+		return nil, err
+	}
+	p := sr.Pipeline()
+	for _, token := range tokens {
+		p.Send("EXISTS", sr.GetKey(token))
+	}
+	replies, err := p.Exec()
+	if err != nil {
+		return nil, err
+	}
+	var alives, expired []string
+	for i, token := range tokens {
+		if n, _ := replies.Int(i); n > 0 {
+			alives = append(alives, token)
+		} else {
+			expired = append(expired, token)
+		}
+	}
+	if len(expired) > 0 {
+		args := append([]any{userKey}, StrToList(expired)...)
+		_, _ = sr.Exec("SREM", args...)
+	}
+	return alives, nil
+}
+
+// KickAll 踢掉用户所有会话，返回被踢掉的token
+func (sr *SessionRegistry) KickAll(uid string) ([]string, error) {
+	return sr.KickOthers(uid, "")
+}
+
+// KickOthers 踢掉用户除keep之外的会话，返回被踢掉的token
+func (sr *SessionRegistry) KickOthers(uid, keep string) ([]string, error) {
+	userKey := sr.GetUserKey(uid)
+	var kicked []string
+	replies, err := sr.update(func(p *Pipeline) error {
+		tokens, err := redis.Strings(p.Do("SMEMBERS", userKey))
+		if err != nil {
+			return err
+		}
+		for _, token := range tokens {
+			if token != keep {
+				kicked = append(kicked, token)
+			}
+		}
+		sr.kick(p, userKey, kicked)
+		return nil
+	}, userKey)
+	if err == nil {
+		err = replies.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	sr.forget(kicked)
+	return kicked, nil
+}
+
+// useTx 会话和用户集合能否放在同一个事务中
+func (sr *SessionRegistry) useTx() bool {
+	_, ok := sr.RedisContainer.(*RedisCluster)
+	return !ok && sr.CanTx()
+}
+
+// update 在WATCH事务中执行fn，集群中会话和用户集合不在同一个slot，连接复用也不支持事务
+// 这两种情况改为普通管道依次执行，并发修改同一个用户的会话时以后执行的为准
+func (sr *SessionRegistry) update(fn func(p *Pipeline) error, watches ...string) (Replies, error) {
+	if sr.useTx() {
+		return sr.Tx(fn, watches...)
+	}
+	p := sr.Pipeline()
+	defer p.Close()
+	if err := fn(p); err != nil {
+		return nil, err
+	}
+	return p.Exec()
+}
+
+// kick 在事务中删除会话和临时消息，并移出用户集合，提交成功后再调用 forget
+func (sr *SessionRegistry) kick(p *Pipeline, userKey string, tokens []string) {
+	if len(tokens) == 0 {
+		return
+	}
+	keys := make([]any, 0, len(tokens)*2)
+	for _, token := range tokens {
+		key := sr.GetKey(token)
+		keys = append(keys, key, sr.GetFlashKey(key), legacyFlashKey(key))
+	}
+	p.Send("DEL", keys...)
+	p.Send("SREM", append([]any{userKey}, StrToList(tokens)...)...)
+}
+
+// forget 从本地缓存中移除已被踢掉的会话
+func (sr *SessionRegistry) forget(tokens []string) {
+	for _, token := range tokens {
+		sr.sessions.Remove(sr.GetKey(token))
 	}
-	return false
 }
 
 // 会话
@@ -95,36 +314,105 @@ func (sess *Session) WrapExec(cmd string, args ...any) (any, error) {
 	return sess.reg.Exec(cmd, args...)
 }
 
-// AddFlash 添加临时消息
+func (sess *Session) GetString(field string) (string, error) {
+	return sess.reg.store.Get(sess.name, field)
+}
+
+func (sess *Session) SetVal(field string, value any) (int, error) {
+	return sess.reg.store.Set(sess.name, sess.timeout, map[string]string{field: FormatValue(value)})
+}
+
+// DeleteAll 删除会话和临时消息
+func (sess *Session) DeleteAll() (bool, error) {
+	affects, err := sess.reg.store.Delete(sess.name)
+	return affects > 0, err
+}
+
+// AddFlash 添加临时消息，和会话同时过期
 func (sess *Session) AddFlash(messages ...string) (int, error) {
-	key := fmt.Sprintf("flash:%s", sess.GetKey())
-	args := append([]any{key}, StrToList(messages)...)
-	return redis.Int(sess.WrapExec("RPUSH", args...))
+	return sess.reg.store.PushFlash(sess.name, sess.timeout, messages...)
 }
 
-// GetFlashes 数量n为最大取出多少条消息，-1表示所有消息
+// GetFlashes 数量n为最大取出多少条消息，-1表示所有消息，只读取不删除
 func (sess *Session) GetFlashes(n int) ([]string, error) {
-	end := -1
-	if n > 0 {
-		end = n - 1
+	return sess.reg.store.RangeFlash(sess.name, n)
+}
+
+// PushFlash 添加带类别的临时消息
+func (sess *Session) PushFlash(category string, payload any) (int, error) {
+	msg, err := NewFlashMessage(category, payload)
+	if err != nil {
+		return 0, err
 	}
-	key := fmt.Sprintf("flash:%s", sess.GetKey())
-	return redis.Strings(sess.WrapExec("LRANGE", key, 0, end))
+	return sess.AddFlash(msg.String())
+}
+
+// PopFlashes 取出并删除最多n条消息，-1表示所有消息，刷新页面时不会重复
+func (sess *Session) PopFlashes(n int) ([]FlashMessage, error) {
+	return parseFlashMessages(sess.reg.store.PopFlash(sess.name, n))
+}
+
+// Expire 会话和临时消息一起设置过期时间
+func (sess *Session) Expire(timeout int) (bool, error) {
+	return sess.reg.store.Expire(sess.name, timeout)
+}
+
+func (sess *Session) GetRoles() ([]string, error) {
+	return getRoles(sess.GetString)
 }
 
-// BindRoles 绑定用户角色，返回旧的sid
+// BindRoles 绑定用户角色，返回用户的另一个会话的键，kick为true时踢掉其他所有会话
+// 保留原来的返回值，需要全部会话时使用 BindUser
 func (sess *Session) BindRoles(uid string, roles []string, kick bool) (string, error) {
-	newSid := sess.GetKey()
-	oldSid, _ := sess.reg.Onlines.GetString(uid) // 用于踢掉重复登录
-	if oldSid == newSid {                        // 同一个token
-		oldSid = ""
-	}
-	_, err := sess.reg.Onlines.SetVal(uid, newSid)
-	_, err = sess.SetVal("uid", uid)
-	_, err = sess.SetVal("roles", SessListJoin(roles))
-	if kick && oldSid != "" { // 清空旧的session
-		flashKey := fmt.Sprintf("flash:%s", oldSid)
-		_, err = sess.reg.Delete(oldSid, flashKey)
-	}
-	return oldSid, err
+	others, err := sess.BindUser(uid, roles, kick)
+	if err != nil || len(others) == 0 {
+		return "", err
+	}
+	return sess.reg.GetKey(others[0]), nil
+}
+
+// BindUser 绑定用户角色，同一个用户可以有多个会话，返回其他会话的token
+// kick为true时这些会话已被踢掉，用户集合被并发修改时返回 TxAbortedError
+func (sess *Session) BindUser(uid string, roles []string, kick bool) ([]string, error) {
+	token, err := sess.GetString(SESS_TOKEN_KEY)
+	if err != nil {
+		return nil, err
+	} else if token == "" {
+		return nil, redis.ErrNil
+	}
+	var others []string
+	reg, userKey := sess.reg, sess.reg.GetUserKey(uid)
+	replies, err := reg.update(func(p *Pipeline) error {
+		tokens, err := redis.Strings(p.Do("SMEMBERS", userKey))
+		if err != nil {
+			return err
+		}
+		for _, t := range tokens {
+			if t != token {
+				others = append(others, t)
+			}
+		}
+		sort.Strings(others)
+		if kick {
+			reg.kick(p, userKey, others)
+		}
+		p.Send("SADD", userKey, token)
+		p.Send("EXPIRE", userKey, MAX_TIMEOUT)
+		p.Send("HSET", sess.name, "uid", uid, "roles", SessListJoin(roles))
+		if sess.timeout > 0 {
+			p.Send("EXPIRE", sess.name, sess.timeout)
+			p.Send("EXPIRE", reg.GetFlashKey(sess.name), sess.timeout)
+		}
+		return nil
+	}, userKey)
+	if err == nil {
+		err = replies.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if kick {
+		reg.forget(others)
+	}
+	return others, nil
 }
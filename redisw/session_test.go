@@ -0,0 +1,85 @@
+package redisw_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/azhai/xgen/redisw"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiSessions(t *testing.T) {
+	reg := redisw.NewRegistrySize(redisw.NewRedisFake(), 2)
+	reg.KickAll("u1")
+	web := reg.GetSession("web-token", 60)
+	app := reg.GetSession("app-token", 60)
+	pad := reg.GetSession("pad-token", 60)
+	others, err := web.BindUser("u1", []string{"member"}, false)
+	assert.NoError(t, err)
+	assert.Empty(t, others)
+	others, err = app.BindUser("u1", []string{"member"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web-token"}, others)
+	oldSid, err := pad.BindRoles("u1", []string{"member"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "sess:app-token", oldSid)
+	tokens, err := reg.ListSessions("u1")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"web-token", "app-token", "pad-token"}, tokens)
+
+	assert.True(t, reg.DelSession("pad-token"))
+	kicked, err := reg.KickOthers("u1", "web-token")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app-token"}, kicked)
+	tokens, _ = reg.ListSessions("u1")
+	assert.Equal(t, []string{"web-token"}, tokens)
+
+	app = reg.GetSession("app-token", 60)
+	kicked, err = app.BindUser("u1", nil, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web-token"}, kicked)
+	kicked, _ = reg.KickAll("u1")
+	assert.Equal(t, []string{"app-token"}, kicked)
+
+	// 没有token的会话不能加入用户集合
+	lost := redisw.NewSession(reg, "sess:lost", 60)
+	_, err = lost.BindUser("u1", nil, false)
+	assert.ErrorIs(t, err, redis.ErrNil)
+	members, _ := redis.Strings(reg.Exec("SMEMBERS", "onlines:u1"))
+	assert.Empty(t, members)
+}
+
+func TestFakeSessions(t *testing.T) {
+	r := redisw.NewRedisFake()
+	reg := redisw.NewRegistry(r)
+	web, app := reg.GetSession("web", 60), reg.GetSession("app", 60)
+	web.BindRoles("u1", []string{"admin"}, false)
+	app.BindRoles("u1", []string{"admin"}, false)
+	app.AddFlash("hello")
+	tokens, err := reg.ListSessions("u1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"app", "web"}, tokens)
+	oldSid, err := web.BindRoles("u1", []string{"admin", "member"}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "sess:app", oldSid)
+	exists, _ := r.Exec("EXISTS", "sess:app", "flash:{sess:app}")
+	assert.Equal(t, int64(0), exists)
+	roles, _ := web.GetRoles()
+	assert.Equal(t, []string{"admin", "member"}, roles)
+
+	fake := r.RedisContainer.(*redisw.FakeContainer)
+	web.AddFlash("hi")
+	fake.Advance(30 * time.Second)
+	web.SetVal("name", "bob") // 写入会话时临时消息也要续期
+	assert.Equal(t, 60, r.GetTimeout("sess:web"))
+	assert.Equal(t, 60, r.GetTimeout("flash:{sess:web}"))
+	fake.Advance(40 * time.Second)
+	flashes, err := web.GetFlashes(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"hi"}, flashes)
+
+	fake.Advance(2 * time.Minute)
+	tokens, _ = reg.ListSessions("u1")
+	assert.Empty(t, tokens)
+}
@@ -0,0 +1,150 @@
+package redisw
+
+import (
+	"github.com/gomodule/redigo/redis"
+)
+
+type RedisSet struct {
+	name    string
+	timeout int
+	*RedisWrapper
+}
+
+func NewRedisSet(r *RedisWrapper, name string, timeout int) *RedisSet {
+	return &RedisSet{RedisWrapper: r, name: name, timeout: timeout}
+}
+
+func (rs *RedisSet) Exec(cmd string, args ...any) (any, error) {
+	args = append([]any{rs.name}, args...)
+	return rs.RedisWrapper.Exec(cmd, args...)
+}
+
+// GetSize 获取集合元素数量
+func (rs *RedisSet) GetSize() int {
+	size, _ := redis.Int(rs.Exec("SCARD"))
+	return size
+}
+
+// GetTimeout 获取剩余时间 -1=无限 -2=不存在 -3=出错
+func (rs *RedisSet) GetTimeout(predict bool) int {
+	timeout := rs.RedisWrapper.GetTimeout(rs.name)
+	if timeout == -2 && predict { // 尚未设置，使用预定值
+		timeout = rs.timeout
+	}
+	return timeout
+}
+
+func (rs *RedisSet) Expire(timeout int) (bool, error) {
+	return rs.RedisWrapper.Expire(rs.name, timeout)
+}
+
+func (rs *RedisSet) DeleteAll() (bool, error) {
+	affects, err := rs.RedisWrapper.Delete(rs.name)
+	return affects > 0, err
+}
+
+// Add 添加成员，返回新增的数量
+func (rs *RedisSet) Add(members ...any) (int, error) {
+	if len(members) == 0 {
+		return 0, KeysEmptyError
+	}
+	defer rs.Exec("EXPIRE", rs.timeout)
+	return redis.Int(rs.Exec("SADD", members...))
+}
+
+func (rs *RedisSet) AddStrings(members ...string) (int, error) {
+	return rs.Add(StrToList(members)...)
+}
+
+func (rs *RedisSet) OrigDelete(members ...any) (int, error) {
+	if len(members) == 0 {
+		return 0, KeysEmptyError
+	}
+	return redis.Int(rs.Exec("SREM", members...))
+}
+
+func (rs *RedisSet) Delete(members ...string) (int, error) {
+	return rs.OrigDelete(StrToList(members)...)
+}
+
+func (rs *RedisSet) IsMember(member any) (bool, error) {
+	return redis.Bool(rs.Exec("SISMEMBER", member))
+}
+
+func (rs *RedisSet) OrigMulti(cmd string, members ...any) (any, error) {
+	if len(members) == 0 {
+		return nil, KeysEmptyError
+	}
+	return rs.Exec(cmd, members...)
+}
+
+func (rs *RedisSet) GetMulti(members ...string) (any, error) {
+	return rs.OrigMulti("SMISMEMBER", StrToList(members)...)
+}
+
+// AreMembers 多个成员是否在集合中，1=是 0=否
+func (rs *RedisSet) AreMembers(members ...string) (map[string]int, error) {
+	return ExecMapInt(rs.GetMulti, members...)
+}
+
+func (rs *RedisSet) GetMembers() ([]string, error) {
+	return redis.Strings(rs.Exec("SMEMBERS"))
+}
+
+// Pop 随机取出一个成员
+func (rs *RedisSet) Pop() (string, error) {
+	return redis.String(rs.Exec("SPOP"))
+}
+
+// RandMembers 随机读取n个成员，n为负数时可能重复
+func (rs *RedisSet) RandMembers(n int) ([]string, error) {
+	return redis.Strings(rs.Exec("SRANDMEMBER", n))
+}
+
+// MoveTo 将成员移到另一个集合
+func (rs *RedisSet) MoveTo(dest string, member any) (bool, error) {
+	return redis.Bool(rs.RedisWrapper.Exec("SMOVE", rs.name, dest, member))
+}
+
+// //////////////////////////////////////////////////////////
+// / redis set 的集合运算                                  ///
+// //////////////////////////////////////////////////////////
+
+func (rs *RedisSet) algebra(cmd string, others []string) ([]string, error) {
+	return redis.Strings(rs.Exec(cmd, StrToList(others)...))
+}
+
+func (rs *RedisSet) algebraStore(cmd, dest string, others []string) (int, error) {
+	args := append([]any{dest, rs.name}, StrToList(others)...)
+	return redis.Int(rs.RedisWrapper.Exec(cmd, args...))
+}
+
+// Inter 和其他集合的交集
+func (rs *RedisSet) Inter(others ...string) ([]string, error) {
+	return rs.algebra("SINTER", others)
+}
+
+// Union 和其他集合的并集
+func (rs *RedisSet) Union(others ...string) ([]string, error) {
+	return rs.algebra("SUNION", others)
+}
+
+// Diff 和其他集合的差集
+func (rs *RedisSet) Diff(others ...string) ([]string, error) {
+	return rs.algebra("SDIFF", others)
+}
+
+// InterStore 交集保存到dest中，返回结果数量
+func (rs *RedisSet) InterStore(dest string, others ...string) (int, error) {
+	return rs.algebraStore("SINTERSTORE", dest, others)
+}
+
+// UnionStore 并集保存到dest中，返回结果数量
+func (rs *RedisSet) UnionStore(dest string, others ...string) (int, error) {
+	return rs.algebraStore("SUNIONSTORE", dest, others)
+}
+
+// DiffStore 差集保存到dest中，返回结果数量
+func (rs *RedisSet) DiffStore(dest string, others ...string) (int, error) {
+	return rs.algebraStore("SDIFFSTORE", dest, others)
+}
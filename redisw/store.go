@@ -0,0 +1,657 @@
+package redisw
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const MEM_SESS_SWEEP_INTERVAL = time.Minute // 内存会话清理过期数据的间隔
+
+// ISession 会话的通用操作，*Session 和 *StoreSession 都实现了
+type ISession interface {
+	GetKey() string
+	GetString(field string) (string, error)
+	SetVal(field string, value any) (int, error)
+	GetTimeout(predict bool) int
+	Expire(timeout int) (bool, error)
+	DeleteAll() (bool, error)
+	AddFlash(messages ...string) (int, error)
+	GetFlashes(n int) ([]string, error)
+	PushFlash(category string, payload any) (int, error)
+	PopFlashes(n int) ([]FlashMessage, error)
+	GetRoles() ([]string, error)
+}
+
+var (
+	_ ISession = (*Session)(nil)
+	_ ISession = (*StoreSession)(nil)
+
+	_ SessionStore = (*RedisSessionStore)(nil)
+	_ SessionStore = (*MemorySessionStore)(nil)
+	_ SessionStore = (*KVSessionStore)(nil)
+)
+
+// SessionStore 会话的存储，每个会话是一组字段加上一个临时消息列表
+// 过期时间的约定和redis一致 -1=无限 -2=不存在
+type SessionStore interface {
+	Get(key, field string) (string, error) // 字段不存在时返回 redis.ErrNil
+	GetAll(key string) (map[string]string, error)
+	Set(key string, timeout int, fields map[string]string) (int, error)
+	Delete(keys ...string) (int, error) // 同时删除临时消息
+	TTL(key string) int
+	Expire(key string, timeout int) (bool, error)
+	PushFlash(key string, timeout int, messages ...string) (int, error) // 和会话同时过期
+	RangeFlash(key string, n int) ([]string, error)
+	PopFlash(key string, n int) ([]string, error) // 原子地取出并删除
+}
+
+// FormatValue 和redigo一样将值转为字符串
+func FormatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// GetFlashKey 会话临时消息的键名，在集群中和会话在同一个slot
+func (r *RedisWrapper) GetFlashKey(sessKey string) string {
+	return fmt.Sprintf("%s:%s", SESS_FLASH_PREFIX, r.SlotTag(sessKey))
+}
+
+// legacyFlashKey 旧版本临时消息的键名，读取和删除时兼顾，不再写入
+func legacyFlashKey(sessKey string) string {
+	return fmt.Sprintf("%s:%s", SESS_FLASH_PREFIX, sessKey)
+}
+
+// //////////////////////////////////////////////////////////
+// / 存储在redis中                                          ///
+// //////////////////////////////////////////////////////////
+
+// RedisSessionStore 会话保存在哈希表中，临时消息保存在列表中
+type RedisSessionStore struct {
+	*RedisWrapper
+}
+
+func NewRedisSessionStore(r *RedisWrapper) *RedisSessionStore {
+	return &RedisSessionStore{RedisWrapper: r}
+}
+
+func (s *RedisSessionStore) Get(key, field string) (string, error) {
+	return redis.String(s.Exec("HGET", key, field))
+}
+
+func (s *RedisSessionStore) GetAll(key string) (map[string]string, error) {
+	return redis.StringMap(s.Exec("HGETALL", key))
+}
+
+func (s *RedisSessionStore) Set(key string, timeout int, fields map[string]string) (int, error) {
+	if len(fields) == 0 {
+		return 0, KeysEmptyError
+	}
+	args := []any{key}
+	for field, value := range fields {
+		args = append(args, field, value)
+	}
+	p := s.Pipeline().Send("HSET", args...)
+	if timeout > 0 { // 临时消息和会话一起续期
+		p.Send("EXPIRE", key, timeout).Send("EXPIRE", s.GetFlashKey(key), timeout)
+	}
+	replies, err := p.Exec()
+	if err != nil {
+		return 0, err
+	}
+	return replies.Int(0)
+}
+
+func (s *RedisSessionStore) Delete(keys ...string) (int, error) {
+	args := make([]string, 0, len(keys)*2)
+	for _, key := range keys {
+		args = append(args, key, s.GetFlashKey(key), legacyFlashKey(key))
+	}
+	return s.RedisWrapper.Delete(args...)
+}
+
+func (s *RedisSessionStore) TTL(key string) int {
+	return s.GetTimeout(key)
+}
+
+func (s *RedisSessionStore) Expire(key string, timeout int) (bool, error) {
+	p := s.Pipeline().Send("EXPIRE", key, timeout)
+	replies, err := p.Send("EXPIRE", s.GetFlashKey(key), timeout).Exec()
+	if err != nil {
+		return false, err
+	}
+	n, err := replies.Int(0)
+	return n > 0, err
+}
+
+func (s *RedisSessionStore) PushFlash(key string, timeout int, messages ...string) (int, error) {
+	return pushFlashes(s.RedisWrapper, key, timeout, messages...)
+}
+
+func (s *RedisSessionStore) RangeFlash(key string, n int) ([]string, error) {
+	return rangeFlashes(s.RedisWrapper, key, n)
+}
+
+func (s *RedisSessionStore) PopFlash(key string, n int) ([]string, error) {
+	return popFlashes(s.RedisWrapper, key, n)
+}
+
+// //////////////////////////////////////////////////////////
+// / 存储在内存中                                           ///
+// //////////////////////////////////////////////////////////
+
+type memSession struct {
+	fields   map[string]string
+	flashes  []string
+	expireAt time.Time // 零值表示无限
+}
+
+// MemorySessionStore 会话保存在进程内存中，用于不想依赖redis的小工具
+// 过期的会话在读取时删除，写入时也会定期清理一遍，不需要后台协程
+type MemorySessionStore struct {
+	sessions      map[string]*memSession
+	Now           func() time.Time // 当前时间，测试时可以替换
+	SweepInterval time.Duration    // 写入时清理过期会话的最小间隔
+	lastSweep     time.Time
+	mu            sync.Mutex
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]*memSession),
+		Now: time.Now, SweepInterval: MEM_SESS_SWEEP_INTERVAL}
+}
+
+// Sweep 立即清理所有过期的会话，返回清理的数量
+func (s *MemorySessionStore) Sweep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sweep(true)
+}
+
+// sweep 清理过期的会话，force为false时距离上次不足 SweepInterval 则跳过，调用者需要持有mu
+func (s *MemorySessionStore) sweep(force bool) (count int) {
+	now := s.Now()
+	if !force && now.Sub(s.lastSweep) < s.SweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, sess := range s.sessions {
+		if !sess.expireAt.IsZero() && !now.Before(sess.expireAt) {
+			delete(s.sessions, key)
+			count++
+		}
+	}
+	return
+}
+
+// lookup 找到未过期的会话，调用者需要持有mu
+func (s *MemorySessionStore) lookup(key string, create bool) *memSession {
+	sess, ok := s.sessions[key]
+	if ok && !sess.expireAt.IsZero() && !s.Now().Before(sess.expireAt) {
+		delete(s.sessions, key)
+		ok = false
+	}
+	if !ok && create {
+		sess = &memSession{fields: make(map[string]string)}
+		s.sessions[key] = sess
+		ok = true
+	}
+	if !ok {
+		return nil
+	}
+	return sess
+}
+
+func (s *MemorySessionStore) expire(sess *memSession, timeout int) {
+	if timeout > 0 {
+		sess.expireAt = s.Now().Add(time.Duration(timeout) * time.Second)
+	}
+}
+
+func (s *MemorySessionStore) Get(key, field string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess := s.lookup(key, false); sess != nil {
+		if value, ok := sess.fields[field]; ok {
+			return value, nil
+		}
+	}
+	return "", redis.ErrNil
+}
+
+func (s *MemorySessionStore) GetAll(key string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make(map[string]string)
+	if sess := s.lookup(key, false); sess != nil {
+		for field, value := range sess.fields {
+			result[field] = value
+		}
+	}
+	return result, nil
+}
+
+func (s *MemorySessionStore) Set(key string, timeout int, fields map[string]string) (int, error) {
+	if len(fields) == 0 {
+		return 0, KeysEmptyError
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(false)
+	sess, added := s.lookup(key, true), 0
+	for field, value := range fields {
+		if _, ok := sess.fields[field]; !ok {
+			added++
+		}
+		sess.fields[field] = value
+	}
+	s.expire(sess, timeout)
+	return added, nil
+}
+
+func (s *MemorySessionStore) Delete(keys ...string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, key := range keys {
+		if s.lookup(key, false) != nil {
+			delete(s.sessions, key)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemorySessionStore) TTL(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.lookup(key, false)
+	if sess == nil {
+		return SESS_NOT_EXISTS
+	} else if sess.expireAt.IsZero() {
+		return SESS_FOR_EVER
+	}
+	return int(sess.expireAt.Sub(s.Now()).Seconds())
+}
+
+func (s *MemorySessionStore) Expire(key string, timeout int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.lookup(key, false)
+	if sess == nil {
+		return false, nil
+	}
+	if timeout <= 0 { // 和redis一样立即过期
+		delete(s.sessions, key)
+	} else {
+		s.expire(sess, timeout)
+	}
+	return true, nil
+}
+
+func (s *MemorySessionStore) PushFlash(key string, timeout int, messages ...string) (int, error) {
+	if len(messages) == 0 {
+		return 0, KeysEmptyError
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(false)
+	sess := s.lookup(key, true)
+	sess.flashes = append(sess.flashes, messages...)
+	if sess.expireAt.IsZero() {
+		s.expire(sess, timeout)
+	}
+	return len(sess.flashes), nil
+}
+
+func (s *MemorySessionStore) RangeFlash(key string, n int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.lookup(key, false)
+	if sess == nil {
+		return nil, nil
+	}
+	if n <= 0 || n > len(sess.flashes) {
+		n = len(sess.flashes)
+	}
+	return append([]string{}, sess.flashes[:n]...), nil
+}
+
+func (s *MemorySessionStore) PopFlash(key string, n int) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess := s.lookup(key, false)
+	if sess == nil {
+		return nil, nil
+	}
+	if n <= 0 || n > len(sess.flashes) {
+		n = len(sess.flashes)
+	}
+	flashes := append([]string{}, sess.flashes[:n]...)
+	sess.flashes = sess.flashes[n:]
+	return flashes, nil
+}
+
+// //////////////////////////////////////////////////////////
+// / 存储在键值数据库中                                     ///
+// //////////////////////////////////////////////////////////
+
+// KVTx 键值数据库的事务，flashdb的 *flashdb.Tx 实现了这些方法
+type KVTx interface {
+	Get(key string) (string, error)
+	Set(key, value string) error
+	SetEx(key, value string, duration int64) error
+	TTL(key string) int64 // 不过期时不大于0
+	Delete(key string) error
+	Expire(key string, duration int64) error
+}
+
+// KVStore 支持读写事务的键值数据库，flashdb需要包装一下回调的参数类型
+type KVStore interface {
+	View(fn func(tx KVTx) error) error
+	Update(fn func(tx KVTx) error) error
+}
+
+// kvSession 会话整体序列化为Json保存
+type kvSession struct {
+	Fields  map[string]string `json:"f"`
+	Flashes []string          `json:"m,omitempty"`
+}
+
+// KVSessionStore 每个会话保存为键值数据库中的一个键，例如flashdb
+type KVSessionStore struct {
+	KVStore
+}
+
+func NewKVSessionStore(db KVStore) *KVSessionStore {
+	return &KVSessionStore{KVStore: db}
+}
+
+func (s *KVSessionStore) load(tx KVTx, key string) *kvSession {
+	sess := &kvSession{Fields: make(map[string]string)}
+	if value, err := tx.Get(key); err == nil {
+		_ = json.Unmarshal([]byte(value), sess)
+	}
+	return sess
+}
+
+// save 写回会话，timeout<=0时保留原来的过期时间
+func (s *KVSessionStore) save(tx KVTx, key string, timeout int, sess *kvSession) error {
+	value, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	if timeout <= 0 {
+		timeout = int(tx.TTL(key))
+	}
+	if timeout > 0 {
+		return tx.SetEx(key, string(value), int64(timeout))
+	}
+	return tx.Set(key, string(value))
+}
+
+func (s *KVSessionStore) Get(key, field string) (value string, err error) {
+	err = s.View(func(tx KVTx) error {
+		var ok bool
+		if value, ok = s.load(tx, key).Fields[field]; !ok {
+			return redis.ErrNil
+		}
+		return nil
+	})
+	return
+}
+
+func (s *KVSessionStore) GetAll(key string) (fields map[string]string, err error) {
+	err = s.View(func(tx KVTx) error {
+		fields = s.load(tx, key).Fields
+		return nil
+	})
+	return
+}
+
+func (s *KVSessionStore) Set(key string, timeout int, fields map[string]string) (added int, err error) {
+	if len(fields) == 0 {
+		return 0, KeysEmptyError
+	}
+	err = s.Update(func(tx KVTx) error {
+		sess := s.load(tx, key)
+		for field, value := range fields {
+			if _, ok := sess.Fields[field]; !ok {
+				added++
+			}
+			sess.Fields[field] = value
+		}
+		return s.save(tx, key, timeout, sess)
+	})
+	return
+}
+
+func (s *KVSessionStore) Delete(keys ...string) (count int, err error) {
+	err = s.Update(func(tx KVTx) error {
+		for _, key := range keys {
+			if _, err := tx.Get(key); err == nil {
+				count++
+				_ = tx.Delete(key)
+			}
+		}
+		return nil
+	})
+	return
+}
+
+func (s *KVSessionStore) TTL(key string) (ttl int) {
+	_ = s.View(func(tx KVTx) error {
+		if _, err := tx.Get(key); err != nil {
+			ttl = SESS_NOT_EXISTS
+		} else if ttl = int(tx.TTL(key)); ttl <= 0 {
+			ttl = SESS_FOR_EVER
+		}
+		return nil
+	})
+	return
+}
+
+func (s *KVSessionStore) Expire(key string, timeout int) (ok bool, err error) {
+	err = s.Update(func(tx KVTx) error {
+		if _, err := tx.Get(key); err != nil {
+			return nil
+		}
+		ok = true
+		if timeout <= 0 { // 和redis一样立即过期
+			return tx.Delete(key)
+		}
+		return tx.Expire(key, int64(timeout))
+	})
+	return
+}
+
+func (s *KVSessionStore) PushFlash(key string, timeout int, messages ...string) (size int, err error) {
+	if len(messages) == 0 {
+		return 0, KeysEmptyError
+	}
+	err = s.Update(func(tx KVTx) error {
+		sess := s.load(tx, key)
+		sess.Flashes = append(sess.Flashes, messages...)
+		size = len(sess.Flashes)
+		if int(tx.TTL(key)) > 0 { // 已有会话时不改变过期时间
+			timeout = 0
+		}
+		return s.save(tx, key, timeout, sess)
+	})
+	return
+}
+
+func (s *KVSessionStore) RangeFlash(key string, n int) (flashes []string, err error) {
+	err = s.View(func(tx KVTx) error {
+		flashes = s.load(tx, key).Flashes
+		if n > 0 && n < len(flashes) {
+			flashes = flashes[:n]
+		}
+		return nil
+	})
+	return
+}
+
+func (s *KVSessionStore) PopFlash(key string, n int) (flashes []string, err error) {
+	err = s.Update(func(tx KVTx) error {
+		sess := s.load(tx, key)
+		if len(sess.Flashes) == 0 {
+			return nil
+		}
+		if n <= 0 || n > len(sess.Flashes) {
+			n = len(sess.Flashes)
+		}
+		flashes, sess.Flashes = sess.Flashes[:n], sess.Flashes[n:]
+		return s.save(tx, key, 0, sess)
+	})
+	return
+}
+
+// //////////////////////////////////////////////////////////
+// / 使用存储的会话                                          ///
+// //////////////////////////////////////////////////////////
+
+// StoreSession 不依赖具体存储的会话
+type StoreSession struct {
+	key     string
+	timeout int
+	store   SessionStore
+}
+
+// NewStoreSession 创建或打开会话
+func NewStoreSession(store SessionStore, token string, timeout int) *StoreSession {
+	key := fmt.Sprintf("%s:%s", SESS_PREFIX, token)
+	sess := &StoreSession{key: key, timeout: timeout, store: store}
+	_, _ = sess.SetVal(SESS_TOKEN_KEY, token)
+	return sess
+}
+
+func (sess *StoreSession) GetKey() string {
+	return sess.key
+}
+
+func (sess *StoreSession) GetString(field string) (string, error) {
+	return sess.store.Get(sess.key, field)
+}
+
+func (sess *StoreSession) GetAll() (map[string]string, error) {
+	return sess.store.GetAll(sess.key)
+}
+
+func (sess *StoreSession) SetVal(field string, value any) (int, error) {
+	return sess.store.Set(sess.key, sess.timeout, map[string]string{field: FormatValue(value)})
+}
+
+// GetTimeout 获取剩余时间 -1=无限 -2=不存在 -3=出错
+func (sess *StoreSession) GetTimeout(predict bool) int {
+	timeout := sess.store.TTL(sess.key)
+	if timeout == SESS_NOT_EXISTS && predict { // 尚未设置，使用预定值
+		timeout = sess.timeout
+	}
+	return timeout
+}
+
+func (sess *StoreSession) Expire(timeout int) (bool, error) {
+	return sess.store.Expire(sess.key, timeout)
+}
+
+func (sess *StoreSession) DeleteAll() (bool, error) {
+	affects, err := sess.store.Delete(sess.key)
+	return affects > 0, err
+}
+
+// AddFlash 添加临时消息
+func (sess *StoreSession) AddFlash(messages ...string) (int, error) {
+	return sess.store.PushFlash(sess.key, sess.timeout, messages...)
+}
+
+// GetFlashes 数量n为最大取出多少条消息，-1表示所有消息，只读取不删除
+func (sess *StoreSession) GetFlashes(n int) ([]string, error) {
+	return sess.store.RangeFlash(sess.key, n)
+}
+
+// PushFlash 添加带类别的临时消息
+func (sess *StoreSession) PushFlash(category string, payload any) (int, error) {
+	msg, err := NewFlashMessage(category, payload)
+	if err != nil {
+		return 0, err
+	}
+	return sess.AddFlash(msg.String())
+}
+
+// PopFlashes 取出并删除最多n条消息，-1表示所有消息
+func (sess *StoreSession) PopFlashes(n int) ([]FlashMessage, error) {
+	return parseFlashMessages(sess.store.PopFlash(sess.key, n))
+}
+
+// Rotate 更换会话的token，复制字段和临时消息后删除旧会话
+func (sess *StoreSession) Rotate(newToken string) error {
+	fields, err := sess.store.GetAll(sess.key)
+	if err != nil {
+		return err
+	} else if len(fields) == 0 {
+		return redis.ErrNil
+	}
+	flashes, err := sess.store.PopFlash(sess.key, -1)
+	if err != nil {
+		return err
+	}
+	timeout := sess.store.TTL(sess.key)
+	if timeout <= 0 {
+		timeout = sess.timeout
+	}
+	newKey := fmt.Sprintf("%s:%s", SESS_PREFIX, newToken)
+	fields[SESS_TOKEN_KEY] = newToken
+	if _, err = sess.store.Set(newKey, timeout, fields); err != nil {
+		return err
+	}
+	if len(flashes) > 0 {
+		if _, err = sess.store.PushFlash(newKey, timeout, flashes...); err != nil {
+			return err
+		}
+	}
+	_, err = sess.store.Delete(sess.key)
+	sess.key = newKey
+	return err
+}
+
+// BindRoles 绑定用户角色，不支持多设备管理
+func (sess *StoreSession) BindRoles(uid string, roles []string) error {
+	fields := map[string]string{"uid": uid, "roles": SessListJoin(roles)}
+	_, err := sess.store.Set(sess.key, sess.timeout, fields)
+	return err
+}
+
+func (sess *StoreSession) GetRoles() ([]string, error) {
+	return getRoles(sess.GetString)
+}
+
+func getRoles(getString func(field string) (string, error)) ([]string, error) {
+	roles, err := getString("roles")
+	if err != nil || roles == "" {
+		if err == redis.ErrNil {
+			err = nil
+		}
+		return nil, err
+	}
+	return SessListSplit(roles), nil
+}
@@ -0,0 +1,130 @@
+package redisw_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/azhai/xgen/redisw"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemorySessionStore(t *testing.T) {
+	now := time.Now()
+	store := redisw.NewMemorySessionStore()
+	store.Now = func() time.Time { return now }
+	sess := redisw.NewStoreSession(store, "abc", 60)
+	assert.Equal(t, "sess:abc", sess.GetKey())
+	token, err := sess.GetString("_token_")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", token)
+
+	assert.NoError(t, sess.BindRoles("u1", []string{"admin", "member"}))
+	roles, err := sess.GetRoles()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin", "member"}, roles)
+	sess.AddFlash("saved", "welcome")
+	flashes, _ := sess.GetFlashes(1)
+	assert.Equal(t, []string{"saved"}, flashes)
+	assert.Equal(t, 60, sess.GetTimeout(false))
+
+	now = now.Add(61 * time.Second)
+	assert.Equal(t, -2, sess.GetTimeout(false))
+	_, err = sess.GetString("uid")
+	assert.Error(t, err)
+
+	for i := 0; i < 3; i++ {
+		redisw.NewStoreSession(store, fmt.Sprintf("old%d", i), 60)
+	}
+	now = now.Add(2 * time.Minute)
+	redisw.NewStoreSession(store, "new", 60) // 写入时清理过期的会话
+	assert.Equal(t, 0, store.Sweep())
+}
+
+// memKV 内存中的键值数据库，代替flashdb
+type memKV struct {
+	values  map[string]string
+	expires map[string]time.Time
+	mu      sync.Mutex
+}
+
+func (db *memKV) View(fn func(tx redisw.KVTx) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return fn(db)
+}
+
+func (db *memKV) Update(fn func(tx redisw.KVTx) error) error {
+	return db.View(fn)
+}
+
+func (db *memKV) Get(key string) (string, error) {
+	if at, ok := db.expires[key]; ok && !time.Now().Before(at) {
+		delete(db.values, key)
+		delete(db.expires, key)
+	}
+	value, ok := db.values[key]
+	if !ok {
+		return "", fmt.Errorf("the key %s is not found", key)
+	}
+	return value, nil
+}
+
+func (db *memKV) Set(key, value string) error {
+	db.values[key] = value
+	delete(db.expires, key)
+	return nil
+}
+
+func (db *memKV) SetEx(key, value string, duration int64) error {
+	db.values[key] = value
+	return db.Expire(key, duration)
+}
+
+func (db *memKV) TTL(key string) int64 {
+	if at, ok := db.expires[key]; ok {
+		return int64(time.Until(at).Seconds() + 0.5)
+	}
+	return 0
+}
+
+func (db *memKV) Delete(key string) error {
+	delete(db.values, key)
+	delete(db.expires, key)
+	return nil
+}
+
+func (db *memKV) Expire(key string, duration int64) error {
+	db.expires[key] = time.Now().Add(time.Duration(duration) * time.Second)
+	return nil
+}
+
+func TestKVSessionStore(t *testing.T) {
+	db := &memKV{values: make(map[string]string), expires: make(map[string]time.Time)}
+	store := redisw.NewKVSessionStore(db)
+	sess := redisw.NewStoreSession(store, "abc", 60)
+	assert.NoError(t, sess.BindRoles("u1", []string{"admin"}))
+	roles, err := sess.GetRoles()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"admin"}, roles)
+	_, err = sess.GetString("none")
+	assert.ErrorIs(t, err, redis.ErrNil)
+
+	sess.AddFlash("saved", "welcome")
+	flashes, _ := sess.GetFlashes(1)
+	assert.Equal(t, []string{"saved"}, flashes)
+	msgs, err := sess.PopFlashes(-1)
+	assert.NoError(t, err)
+	assert.Len(t, msgs, 2)
+	assert.Equal(t, 60, sess.GetTimeout(false))
+
+	assert.NoError(t, sess.Rotate("xyz"))
+	assert.Equal(t, "sess:xyz", sess.GetKey())
+	assert.Equal(t, -2, store.TTL("sess:abc"))
+	ok, err := sess.DeleteAll()
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Empty(t, db.values)
+}
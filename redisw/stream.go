@@ -0,0 +1,351 @@
+package redisw
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+const (
+	STREAM_DEFAULT_COUNT       = 100              // 每批读取的消息数
+	STREAM_DEFAULT_BLOCK       = 2 * time.Second  // XREADGROUP 阻塞等待的时长，要小于命令最大执行时长
+	STREAM_DEFAULT_MIN_IDLE    = 60 * time.Second // 消息未确认超过这个时长会被其他消费者认领
+	STREAM_DEFAULT_MAX_DELIVER = 5                // 消息最多投递几次，之后转入死信流
+	STREAM_DEAD_LETTER_SUFFIX  = ":dead"          // 死信流的默认名称是原来的流加上这个后缀
+)
+
+// StreamMessage 流中的一条消息
+type StreamMessage struct {
+	ID     string
+	Values map[string]string
+}
+
+// StreamMessages 解析 XRANGE XREADGROUP 等命令应答中的消息列表
+func StreamMessages(reply any, err error) ([]StreamMessage, error) {
+	var entries []any
+	if entries, err = redis.Values(reply, err); err != nil {
+		return nil, err
+	}
+	result := make([]StreamMessage, 0, len(entries))
+	for _, entry := range entries {
+		var parts []any
+		if parts, err = redis.Values(entry, nil); err != nil {
+			return nil, err
+		} else if len(parts) < 2 {
+			continue
+		}
+		msg := StreamMessage{}
+		if msg.ID, err = redis.String(parts[0], nil); err != nil {
+			return nil, err
+		}
+		if parts[1] != nil { // 已经被删除的消息没有内容
+			if msg.Values, err = redis.StringMap(parts[1], nil); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, msg)
+	}
+	return result, nil
+}
+
+// RedisStream 流，写入时按照maxLen近似裁剪
+type RedisStream struct {
+	name   string
+	maxLen int
+	*RedisWrapper
+}
+
+// NewRedisStream maxLen<=0表示不裁剪
+func NewRedisStream(r *RedisWrapper, name string, maxLen int) *RedisStream {
+	return &RedisStream{RedisWrapper: r, name: name, maxLen: maxLen}
+}
+
+func (rs *RedisStream) Exec(cmd string, args ...any) (any, error) {
+	args = append([]any{rs.name}, args...)
+	return rs.RedisWrapper.Exec(cmd, args...)
+}
+
+// GetSize 获取流中消息数量
+func (rs *RedisStream) GetSize() int {
+	size, _ := redis.Int(rs.Exec("XLEN"))
+	return size
+}
+
+func (rs *RedisStream) DeleteAll() (bool, error) {
+	affects, err := rs.RedisWrapper.Delete(rs.name)
+	return affects > 0, err
+}
+
+// Add 写入消息，返回消息ID
+func (rs *RedisStream) Add(values Map) (string, error) {
+	if len(values) == 0 {
+		return "", KeysEmptyError
+	}
+	var args []any
+	if rs.maxLen > 0 {
+		args = append(args, "MAXLEN", "~", rs.maxLen)
+	}
+	args = append(args, "*")
+	args = append(args, Map2Args(values, false)...)
+	return redis.String(rs.Exec("XADD", args...))
+}
+
+// Range 按照ID范围读取消息，可以使用 - + 表示两端
+func (rs *RedisStream) Range(start, end string, count int) ([]StreamMessage, error) {
+	args := []any{start, end}
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	return StreamMessages(rs.Exec("XRANGE", args...))
+}
+
+// CreateGroup 创建消费组，start为$表示只消费新消息，已存在时不报错
+func (rs *RedisStream) CreateGroup(group, start string) error {
+	_, err := rs.RedisWrapper.Exec("XGROUP", "CREATE", rs.name, group, start, "MKSTREAM")
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		err = nil
+	}
+	return err
+}
+
+// Ack 确认消息已处理
+func (rs *RedisStream) Ack(group string, ids ...string) (int, error) {
+	if len(ids) == 0 {
+		return 0, KeysEmptyError
+	}
+	args := append([]any{group}, StrToList(ids)...)
+	return redis.Int(rs.Exec("XACK", args...))
+}
+
+// ReadGroup 以消费者身份读取新消息，没有消息时阻塞block时长
+func (rs *RedisStream) ReadGroup(group, consumer string, count int, block time.Duration) ([]StreamMessage, error) {
+	args := []any{"GROUP", group, consumer, "COUNT", count}
+	if block > 0 {
+		args = append(args, "BLOCK", block.Milliseconds())
+	}
+	args = append(args, "STREAMS", rs.name, ">")
+	streams, err := redis.Values(rs.RedisWrapper.Exec("XREADGROUP", args...))
+	if err != nil || len(streams) == 0 { // 超时返回空
+		if err == redis.ErrNil {
+			err = nil
+		}
+		return nil, err
+	}
+	var parts []any // 只读一个流 [[name, messages]]
+	if parts, err = redis.Values(streams[0], nil); err != nil || len(parts) < 2 {
+		return nil, err
+	}
+	return StreamMessages(parts[1], nil)
+}
+
+// AutoClaim 认领空闲超过minIdle的待确认消息，返回下一个游标
+func (rs *RedisStream) AutoClaim(group, consumer string, minIdle time.Duration,
+	cursor string, count int) (string, []StreamMessage, error) {
+	values, err := redis.Values(rs.Exec("XAUTOCLAIM", group, consumer,
+		minIdle.Milliseconds(), cursor, "COUNT", count))
+	if err != nil {
+		return "", nil, err
+	} else if len(values) < 2 {
+		return "0-0", nil, nil
+	}
+	if cursor, err = redis.String(values[0], nil); err != nil {
+		return "", nil, err
+	}
+	msgs, err := StreamMessages(values[1], nil)
+	return cursor, msgs, err
+}
+
+// Pending 待确认消息的投递次数，ID在start和end之间，consumer为空时不限消费者
+func (rs *RedisStream) Pending(group, start, end string, count int, consumer string) (map[string]int, error) {
+	args := []any{group, start, end, count}
+	if consumer != "" {
+		args = append(args, consumer)
+	}
+	entries, err := redis.Values(rs.Exec("XPENDING", args...))
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]int, len(entries))
+	for _, entry := range entries { // [id, consumer, idle, deliveries]
+		var parts []any
+		if parts, err = redis.Values(entry, nil); err != nil {
+			return nil, err
+		} else if len(parts) < 4 {
+			continue
+		}
+		id, _ := redis.String(parts[0], nil)
+		result[id], _ = redis.Int(parts[3], nil)
+	}
+	return result, nil
+}
+
+// StreamWorker 消费组中的一个消费者，批量处理成功后确认
+// 阻塞读取会占住连接，不要使用连接复用
+type StreamWorker struct {
+	group      string
+	consumer   string
+	Count      int           // 每批最多处理的消息数
+	Block      time.Duration // 没有新消息时阻塞等待的时长
+	MinIdle    time.Duration // 认领其他消费者超时未确认的消息
+	MaxDeliver int           // 认领时投递次数超过它的消息不再处理，不大于0时不限制
+	DeadLetter string        // 死信流，为空时只确认并报告错误
+	OnError    func(err error)
+	*RedisStream
+}
+
+// NewWorker 创建消费者，同一个组内consumer要唯一
+func (rs *RedisStream) NewWorker(group, consumer string) *StreamWorker {
+	return &StreamWorker{
+		RedisStream: rs, group: group, consumer: consumer,
+		Count: STREAM_DEFAULT_COUNT, Block: STREAM_DEFAULT_BLOCK,
+		MinIdle: STREAM_DEFAULT_MIN_IDLE, MaxDeliver: STREAM_DEFAULT_MAX_DELIVER,
+		DeadLetter: rs.name + STREAM_DEAD_LETTER_SUFFIX,
+	}
+}
+
+// Run 循环读取并交给sink批量处理，sink出错时不确认，等超时后被重新认领
+// sink的签名和 xquery.UpdateSlice 的batch参数一致，直到ctx结束
+func (w *StreamWorker) Run(ctx context.Context, sink func(msgs []StreamMessage) error) error {
+	if err := w.CreateGroup(w.group, "0"); err != nil {
+		return err
+	}
+	var lastClaim time.Time
+	for ctx.Err() == nil {
+		if time.Since(lastClaim) >= w.MinIdle {
+			lastClaim = time.Now()
+			w.claim(ctx, sink)
+		}
+		msgs, err := w.ReadGroup(w.group, w.consumer, w.Count, w.Block)
+		if err != nil {
+			w.report(err)
+			w.sleep(ctx)
+			continue
+		}
+		w.handle(msgs, sink)
+	}
+	return nil
+}
+
+// JsonRowsSink 把消息中field字段的Json对象解码为一批数据行，交给insert写入，例如 xquery.InsertMaps
+// 解码失败的消息不是临时错误，会被跳过并确认
+func JsonRowsSink(field string, insert func(rows []map[string]any) error) func(msgs []StreamMessage) error {
+	return func(msgs []StreamMessage) error {
+		rows := make([]map[string]any, 0, len(msgs))
+		for _, msg := range msgs {
+			var row map[string]any
+			if err := json.Unmarshal([]byte(msg.Values[field]), &row); err == nil && len(row) > 0 {
+				rows = append(rows, row)
+			}
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return insert(rows)
+	}
+}
+
+// claim 认领全部超时的待确认消息，包括自己上次没处理完的
+func (w *StreamWorker) claim(ctx context.Context, sink func(msgs []StreamMessage) error) {
+	cursor := "0-0"
+	for ctx.Err() == nil {
+		next, msgs, err := w.AutoClaim(w.group, w.consumer, w.MinIdle, cursor, w.Count)
+		if err != nil {
+			w.report(err)
+			return
+		}
+		w.handle(w.dropPoison(msgs), sink)
+		if cursor = next; cursor == "0-0" {
+			return
+		}
+	}
+}
+
+// dropPoison 投递次数过多的消息转入死信流并确认，返回其余的消息
+// sink按批处理，一批中有一条总是失败时，整批都会被转入死信流
+func (w *StreamWorker) dropPoison(msgs []StreamMessage) []StreamMessage {
+	if w.MaxDeliver <= 0 || len(msgs) == 0 {
+		return msgs
+	}
+	first, last := msgs[0].ID, msgs[len(msgs)-1].ID
+	counts, err := w.Pending(w.group, first, last, len(msgs), w.consumer)
+	if err != nil {
+		w.report(err)
+		return msgs
+	}
+	rest := make([]StreamMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		n := counts[msg.ID]
+		if n <= w.MaxDeliver || msg.Values == nil {
+			rest = append(rest, msg)
+			continue
+		}
+		if err = w.deadLetter(msg, n); err != nil {
+			w.report(err)
+			rest = append(rest, msg) // 转移失败，下次认领时再试
+			continue
+		}
+		w.report(fmt.Errorf("the message %s of stream %s was delivered %d times, dropped from group %s",
+			msg.ID, w.name, n, w.group))
+	}
+	return rest
+}
+
+// deadLetter 把消息写入死信流后确认，没有死信流时直接确认
+func (w *StreamWorker) deadLetter(msg StreamMessage, deliveries int) error {
+	if w.DeadLetter != "" {
+		values := NewMap()
+		for k, v := range msg.Values {
+			values[k] = v
+		}
+		values["_id"], values["_deliveries"] = msg.ID, deliveries
+		dead := NewRedisStream(w.RedisWrapper, w.DeadLetter, w.maxLen)
+		if _, err := dead.Add(values); err != nil {
+			return err
+		}
+	}
+	_, err := w.Ack(w.group, msg.ID)
+	return err
+}
+
+func (w *StreamWorker) handle(msgs []StreamMessage, sink func(msgs []StreamMessage) error) {
+	var ids []string
+	valid := make([]StreamMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		ids = append(ids, msg.ID)
+		if msg.Values != nil {
+			valid = append(valid, msg)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if len(valid) > 0 {
+		if err := sink(valid); err != nil {
+			w.report(err)
+			return
+		}
+	}
+	if _, err := w.Ack(w.group, ids...); err != nil {
+		w.report(err)
+	}
+}
+
+func (w *StreamWorker) report(err error) {
+	if w.OnError != nil {
+		w.OnError(err)
+	}
+}
+
+// sleep 出错后等待一会，避免空转
+func (w *StreamWorker) sleep(ctx context.Context) {
+	timer := time.NewTimer(w.Block)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,111 @@
+package redisw_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azhai/xgen/redisw"
+	"github.com/azhai/xgen/xquery"
+	"github.com/gomodule/redigo/redis"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"xorm.io/xorm"
+)
+
+func TestStreamWorker(t *testing.T) {
+	r := GetRedis(t)
+	stream := redisw.NewRedisStream(r, "test:stream", 1000)
+	stream.DeleteAll()
+	for i := 0; i < 5; i++ {
+		_, err := stream.Add(redisw.Map{"n": i})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 5, stream.GetSize())
+
+	worker := stream.NewWorker("test", "c1")
+	worker.Count, worker.Block = 2, 100*time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	var got []string
+	err := worker.Run(ctx, func(msgs []redisw.StreamMessage) error {
+		for _, msg := range msgs {
+			got = append(got, msg.Values["n"])
+		}
+		if len(got) >= 5 {
+			cancel()
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0", "1", "2", "3", "4"}, got)
+	pending, err := r.Exec("XPENDING", "test:stream", "test")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), pending.([]any)[0])
+}
+
+func TestStreamDeadLetter(t *testing.T) {
+	r := GetRedis(t)
+	stream := redisw.NewRedisStream(r, "test:poison", 1000)
+	stream.DeleteAll()
+	r.Delete("test:poison:dead")
+	_, err := stream.Add(redisw.Map{"n": 1})
+	assert.NoError(t, err)
+
+	worker := stream.NewWorker("test", "c1")
+	worker.Block, worker.MinIdle, worker.MaxDeliver = 50*time.Millisecond, 10*time.Millisecond, 2
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	calls := 0
+	worker.OnError = func(err error) {
+		if strings.Contains(err.Error(), "dropped") {
+			cancel()
+		}
+	}
+	err = worker.Run(ctx, func(msgs []redisw.StreamMessage) error {
+		calls++
+		return fmt.Errorf("poison")
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+	dead := redisw.NewRedisStream(r, "test:poison:dead", 0)
+	msgs, err := dead.Range("-", "+", 0)
+	assert.NoError(t, err)
+	if assert.Len(t, msgs, 1) {
+		assert.Equal(t, "1", msgs[0].Values["n"])
+		assert.Equal(t, "3", msgs[0].Values["_deliveries"])
+	}
+	pending, err := redis.Values(r.Exec("XPENDING", "test:poison", "test"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(0), pending[0])
+	}
+}
+
+// TestFakeStreamSink 和web骨架中 SaveMsgData 一样，把消息中的Json写入数据表
+func TestFakeStreamSink(t *testing.T) {
+	eng, err := xorm.NewEngine("sqlite3", filepath.Join(t.TempDir(), "msg.db"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer eng.Close()
+	_, err = eng.Exec("CREATE TABLE t_message (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT, size INTEGER)")
+	assert.NoError(t, err)
+	sink := redisw.JsonRowsSink("data", func(rows []map[string]any) error {
+		return xquery.InsertMaps(eng, "t_message", rows)
+	})
+	msgs := []redisw.StreamMessage{
+		{ID: "1-0", Values: map[string]string{"data": `{"name":"a","size":1}`}},
+		{ID: "2-0", Values: map[string]string{"data": "not json"}}, // 跳过
+		{ID: "3-0", Values: map[string]string{"data": `{"name":"b","size":2}`}},
+	}
+	assert.NoError(t, sink(msgs))
+	var names []string
+	assert.NoError(t, eng.Table("t_message").OrderBy("id").Cols("name").Find(&names))
+	assert.Equal(t, []string{"a", "b"}, names)
+	// 表中没有的字段使整批写入失败，消息不会被确认
+	bad := []redisw.StreamMessage{{ID: "4-0", Values: map[string]string{"data": `{"color":"red"}`}}}
+	assert.Error(t, sink(bad))
+	assert.NoError(t, sink(nil))
+}
@@ -0,0 +1,408 @@
+package redisw
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// 字段的保存方式
+const (
+	fieldScalar  = iota // 数字、字符串和布尔值
+	fieldBytes          // []byte 原样保存
+	fieldTime           // time.Time 保存为RFC3339格式
+	fieldNull           // utils.Null* 等 driver.Valuer ，无效时删除字段
+	fieldForeign        // CacheData 另外保存为Json，字段中只保存id
+	fieldJson           // 其他类型保存为Json
+)
+
+var (
+	cacheDataType = reflect.TypeOf((*CacheData)(nil)).Elem()
+	valuerType    = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	scannerType   = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType      = reflect.TypeOf(time.Time{})
+	bytesType     = reflect.TypeOf([]byte(nil))
+	structFields  sync.Map // reflect.Type => []hashField
+)
+
+// hashField 结构体字段和哈希表字段的对应
+type hashField struct {
+	name  string
+	index []int
+	kind  int
+}
+
+// fieldKind 根据类型决定保存方式，指针按照它指向的类型
+func fieldKind(ft reflect.Type) int {
+	if ft.Implements(cacheDataType) || reflect.PointerTo(ft).Implements(cacheDataType) {
+		return fieldForeign
+	}
+	if ft.Kind() == reflect.Pointer {
+		ft = ft.Elem()
+	}
+	switch {
+	case ft == timeType:
+		return fieldTime
+	case ft.Implements(valuerType) && reflect.PointerTo(ft).Implements(scannerType):
+		return fieldNull
+	case ft == bytesType:
+		return fieldBytes
+	}
+	switch ft.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fieldScalar
+	}
+	return fieldJson
+}
+
+// tagName 优先使用redis标签，其次json标签，最后是字段名
+func tagName(sf reflect.StructField) (name string, skip bool) {
+	for _, key := range []string{"redis", "json"} {
+		if tag, ok := sf.Tag.Lookup(key); ok {
+			name, _, _ = strings.Cut(tag, ",")
+			if name == "-" {
+				return "", true
+			} else if name != "" {
+				return name, false
+			}
+		}
+	}
+	return "", false
+}
+
+// isExtends 匿名的结构体，或者有xorm的extends标签，和xorm一样展开
+func isExtends(sf reflect.StructField) bool {
+	ft := sf.Type
+	if ft.Kind() == reflect.Pointer {
+		ft = ft.Elem()
+	}
+	if ft.Kind() != reflect.Struct || ft == timeType || fieldKind(sf.Type) != fieldJson {
+		return false
+	}
+	return sf.Anonymous || strings.Contains(sf.Tag.Get("xorm"), "extends")
+}
+
+// getHashFields 结构体的所有字段，外层字段优先于展开的字段
+func getHashFields(t reflect.Type) []hashField {
+	if fields, ok := structFields.Load(t); ok {
+		return fields.([]hashField)
+	}
+	var fields []hashField
+	seen := make(map[string]bool)
+	var collect func(t reflect.Type, prefix []int)
+	collect = func(t reflect.Type, prefix []int) {
+		var extends []reflect.StructField
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name, skip := tagName(sf)
+			if skip || (!sf.IsExported() && !sf.Anonymous) {
+				continue
+			}
+			if name == "" && isExtends(sf) {
+				extends = append(extends, sf)
+				continue
+			} else if !sf.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			if !seen[name] {
+				seen[name] = true
+				index := append(append([]int{}, prefix...), i)
+				fields = append(fields, hashField{name: name, index: index, kind: fieldKind(sf.Type)})
+			}
+		}
+		for _, sf := range extends {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			collect(ft, append(append([]int{}, prefix...), sf.Index...))
+		}
+	}
+	collect(t, nil)
+	structFields.Store(t, fields)
+	return fields
+}
+
+// fieldByIndex 按照下标找到字段，alloc为true时创建途中的nil指针，未导出的嵌入指针无法创建
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return v, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func structValue(obj any, needPtr bool) (reflect.Value, error) {
+	v := reflect.ValueOf(obj)
+	if needPtr && (v.Kind() != reflect.Pointer || v.IsNil()) {
+		return v, fmt.Errorf("redisw: LoadStruct needs a non-nil pointer, not %T", obj)
+	}
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return v, fmt.Errorf("redisw: %T is not a struct", obj)
+	}
+	return v, nil
+}
+
+// asCacheData 字段是否非空的CacheData，指针方法也可以
+func asCacheData(fv reflect.Value) (CacheData, bool) {
+	if fv.Kind() == reflect.Pointer && fv.IsNil() {
+		return nil, false
+	}
+	if obj, ok := fv.Interface().(CacheData); ok {
+		return obj, true
+	} else if fv.CanAddr() {
+		obj, ok = fv.Addr().Interface().(CacheData)
+		return obj, ok
+	}
+	return nil, false
+}
+
+// encodeField 字段的值，ok为false时应当删除哈希表中的字段
+func encodeField(fv reflect.Value, kind int) (value any, ok bool, err error) {
+	if kind != fieldNull && fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return nil, false, nil
+		} else if kind != fieldForeign {
+			fv = fv.Elem()
+		}
+	}
+	switch kind {
+	case fieldTime:
+		t := fv.Interface().(time.Time)
+		return t.Format(time.RFC3339Nano), !t.IsZero(), nil
+	case fieldNull:
+		if fv.Kind() == reflect.Pointer && fv.IsNil() {
+			return nil, false, nil
+		}
+		if value, err = fv.Interface().(driver.Valuer).Value(); err != nil || value == nil {
+			return nil, false, err
+		}
+		if t, isTime := value.(time.Time); isTime {
+			value = t.Format(time.RFC3339Nano)
+		}
+		return value, true, nil
+	case fieldBytes, fieldScalar:
+		return fv.Interface(), true, nil
+	}
+	body, err := json.Marshal(fv.Interface())
+	return body, err == nil, err
+}
+
+// decodeField 将哈希表中的字符串写入字段
+func decodeField(fv reflect.Value, kind int, data string) error {
+	if fv.Kind() == reflect.Pointer && kind != fieldForeign {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		if kind == fieldNull {
+			return scanNull(fv.Interface().(sql.Scanner), data)
+		}
+		fv = fv.Elem()
+	}
+	switch kind {
+	case fieldTime:
+		t, err := time.Parse(time.RFC3339Nano, data)
+		if err == nil {
+			fv.Set(reflect.ValueOf(t))
+		}
+		return err
+	case fieldNull:
+		return scanNull(fv.Addr().Interface().(sql.Scanner), data)
+	case fieldBytes:
+		fv.SetBytes([]byte(data))
+		return nil
+	case fieldScalar:
+		return setScalar(fv, data)
+	}
+	if fv.Kind() == reflect.Pointer && fv.IsNil() {
+		fv.Set(reflect.New(fv.Type().Elem()))
+	}
+	if fv.Kind() != reflect.Pointer {
+		fv = fv.Addr()
+	}
+	return json.Unmarshal([]byte(data), fv.Interface())
+}
+
+// scanNull 字符串无法转换时，再尝试作为时间
+func scanNull(s sql.Scanner, data string) error {
+	err := s.Scan(data)
+	if err != nil {
+		if t, e := time.Parse(time.RFC3339Nano, data); e == nil {
+			err = s.Scan(t)
+		}
+	}
+	return err
+}
+
+func setScalar(fv reflect.Value, data string) (err error) {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(data)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(data); err == nil {
+			fv.SetBool(b)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(data, fv.Type().Bits()); err == nil {
+			fv.SetFloat(f)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		if n, err = strconv.ParseInt(data, 10, fv.Type().Bits()); err == nil {
+			fv.SetInt(n)
+		}
+	default:
+		var n uint64
+		if n, err = strconv.ParseUint(data, 10, fv.Type().Bits()); err == nil {
+			fv.SetUint(n)
+		}
+	}
+	return
+}
+
+// SaveStruct 按照redis或json标签保存结构体，展开嵌入的结构体
+// 无效的Null值和nil指针删除对应字段，CacheData另外保存为Json，在同一个事务中写入
+func (rh *RedisHash) SaveStruct(obj any) (bool, error) {
+	v, err := structValue(obj, false)
+	if err != nil {
+		return false, err
+	}
+	timeout := rh.GetTimeout(true)
+	args, dels := []any{rh.name}, []any{rh.name}
+	var foreigns [][]any
+	for _, f := range getHashFields(v.Type()) {
+		fv, ok := fieldByIndex(v, f.index, false)
+		if !ok {
+			continue // 嵌入的指针为nil
+		}
+		if f.kind == fieldForeign {
+			obj, isData := asCacheData(fv)
+			if !isData {
+				dels = append(dels, f.name)
+				continue
+			}
+			id := obj.GetCacheId()
+			if id == "" {
+				continue
+			}
+			body, err := json.Marshal(obj)
+			if err != nil {
+				return false, err
+			}
+			if timeout > 0 {
+				foreigns = append(foreigns, []any{"SETEX", id, timeout, body})
+			} else {
+				foreigns = append(foreigns, []any{"SET", id, body})
+			}
+			args = append(args, f.name, id)
+			continue
+		}
+		value, ok, err := encodeField(fv, f.kind)
+		if err != nil {
+			return false, fmt.Errorf("redisw: field %s: %w", f.name, err)
+		} else if ok {
+			args = append(args, f.name, value)
+		} else {
+			dels = append(dels, f.name)
+		}
+	}
+	replies, err := rh.RedisWrapper.Tx(func(p *Pipeline) error {
+		for _, foreign := range foreigns {
+			p.Send(foreign[0].(string), foreign[1:]...)
+		}
+		if len(dels) > 1 {
+			p.Send("HDEL", dels...)
+		}
+		if len(args) > 1 {
+			p.Send("HSET", args...)
+		}
+		if rh.timeout > 0 {
+			p.Send("EXPIRE", rh.name, rh.timeout)
+		}
+		return nil
+	})
+	if err == nil {
+		err = replies.Err()
+	}
+	return err == nil, err
+}
+
+// LoadStruct 读取 SaveStruct 保存的结构体，CacheData从关联的Json中读取
+// 哈希表不存在时返回 redis.ErrNil
+func (rh *RedisHash) LoadStruct(ptr any) error {
+	v, err := structValue(ptr, true)
+	if err != nil {
+		return err
+	}
+	data, err := redis.StringMap(rh.Exec("HGETALL"))
+	if err != nil {
+		return err
+	} else if len(data) == 0 {
+		return redis.ErrNil
+	}
+	var ids []string
+	var foreigns []hashField
+	for _, f := range getHashFields(v.Type()) {
+		value, ok := data[f.name]
+		if !ok {
+			continue
+		}
+		if f.kind == fieldForeign {
+			ids, foreigns = append(ids, value), append(foreigns, f)
+			continue
+		}
+		fv, ok := fieldByIndex(v, f.index, true)
+		if !ok {
+			continue
+		}
+		if err = decodeField(fv, f.kind, value); err != nil {
+			return fmt.Errorf("redisw: field %s: %w", f.name, err)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	values, err := redis.ByteSlices(rh.RedisWrapper.GetMulti(ids...))
+	if err != nil {
+		return err
+	}
+	for i, f := range foreigns {
+		if values[i] == nil {
+			continue // 关联的数据已过期
+		}
+		fv, ok := fieldByIndex(v, f.index, true)
+		if !ok {
+			continue
+		}
+		if err = decodeField(fv, fieldJson, string(values[i])); err != nil {
+			return fmt.Errorf("redisw: field %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
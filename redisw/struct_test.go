@@ -0,0 +1,87 @@
+package redisw_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/azhai/xgen/redisw"
+	"github.com/azhai/xgen/utils"
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+type testProfile struct {
+	Id   string `json:"id"`
+	Bio  string `json:"bio"`
+	Tags []string
+}
+
+func (p testProfile) GetCacheId() string {
+	return "profile:" + p.Id
+}
+
+type TestStamps struct {
+	CreatedAt time.Time      `json:"created_at"`
+	DeletedAt utils.NullTime `json:"deleted_at"`
+}
+
+type testMember struct {
+	*TestStamps `json:",inline" xorm:"extends"`
+	Id          int64            `redis:"uid" json:"id"`
+	Name        string           `json:"name"`
+	Score       float64          `json:"score"`
+	Active      bool             `json:"active"`
+	Parent      utils.NullInt64  `json:"parent"`
+	Nick        *string          `json:"nick"`
+	Secret      string           `json:"-"`
+	Extra       map[string]int   `json:"extra"`
+	Profile     *testProfile     `json:"profile"`
+	Remark      utils.NullString `redis:"remark"`
+}
+
+func TestFakeStruct(t *testing.T) {
+	r := redisw.NewRedisFake()
+	rh := redisw.NewRedisHash(r, "test:member", 60)
+	now := time.Now().Truncate(time.Second)
+	obj := testMember{Id: 7, Name: "ann", Score: 9.5, Active: true, Secret: "x",
+		Extra: map[string]int{"a": 1}, Profile: &testProfile{Id: "7", Bio: "hi", Tags: []string{"go"}},
+		TestStamps: &TestStamps{CreatedAt: now}}
+	obj.Parent.Int64, obj.Parent.Valid = 3, true
+	ok, err := rh.SaveStruct(obj)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	uid, err := rh.GetVal("uid")
+	assert.NoError(t, err)
+	assert.EqualValues(t, "7", uid)
+	assert.ElementsMatch(t, []string{"uid", "name", "score", "active", "parent",
+		"extra", "profile", "created_at"}, rh.GetKeys())
+
+	var got testMember
+	assert.NoError(t, rh.LoadStruct(&got))
+	assert.Equal(t, int64(7), got.Id)
+	assert.Equal(t, "ann", got.Name)
+	assert.True(t, got.Active)
+	assert.Equal(t, int64(3), got.Parent.Int64)
+	assert.True(t, got.Parent.Valid)
+	assert.False(t, got.Remark.Valid)
+	assert.Nil(t, got.Nick)
+	assert.Empty(t, got.Secret)
+	assert.Equal(t, map[string]int{"a": 1}, got.Extra)
+	assert.Equal(t, []string{"go"}, got.Profile.Tags)
+	assert.True(t, now.Equal(got.CreatedAt))
+	assert.False(t, got.DeletedAt.Valid)
+
+	nick := "annie"
+	obj.Nick, obj.Profile = &nick, nil
+	obj.DeletedAt.Time, obj.DeletedAt.Valid = now, true
+	_, err = rh.SaveStruct(&obj)
+	assert.NoError(t, err)
+	got = testMember{}
+	assert.NoError(t, rh.LoadStruct(&got))
+	assert.Equal(t, "annie", *got.Nick)
+	assert.Nil(t, got.Profile)
+	assert.True(t, now.Equal(got.DeletedAt.Time))
+
+	assert.ErrorIs(t, redisw.NewRedisHash(r, "test:none", 60).LoadStruct(&got), redis.ErrNil)
+	assert.Error(t, rh.LoadStruct(got))
+}
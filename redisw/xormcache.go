@@ -0,0 +1,213 @@
+package redisw
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/azhai/xgen/dialect"
+	"github.com/gomodule/redigo/redis"
+	"xorm.io/xorm"
+	"xorm.io/xorm/caches"
+)
+
+const (
+	XORM_CACHE_PREFIX      = "xorm:"
+	XORM_CACHE_KEY_MAX_LEN = 128 // 超过这个长度的键（通常是SQL）使用md5，带二进制主键的也是
+)
+
+var _ caches.CacheStore = (*RedisCacheStore)(nil)
+
+// RedisCacheStore 实现Xorm的 caches.CacheStore ，缓存主键列表和整行数据
+// 值前面记录了类型名，读取时还原为原来的类型，未登记的类型视为未命中
+type RedisCacheStore struct {
+	*RedisWrapper
+	Namespace string // 键的命名空间，通常是数据库的连接名
+	Timeout   int    // 单位：秒，不大于0时永不过期
+	types     map[string]reflect.Type
+	mu        sync.RWMutex
+}
+
+// NewRedisCacheStore 创建缓存，包装器没有指定编码时使用gob
+func NewRedisCacheStore(r *RedisWrapper, namespace string, timeout int) *RedisCacheStore {
+	if r.Serializer == nil {
+		r = r.WithSerializer(NewSerializer(GobCodec, COMPRESS_NONE))
+	}
+	s := &RedisCacheStore{RedisWrapper: r, Namespace: namespace,
+		Timeout: timeout, types: make(map[string]reflect.Type)}
+	s.Register("")
+	return s
+}
+
+// NewXormCacher 按照配置创建LRU缓存，用于 engine.SetDefaultCacher 或 MapCacher
+func NewXormCacher(r *RedisWrapper, namespace string, cfg dialect.CacheConfig) (*caches.LRUCacher, *RedisCacheStore, error) {
+	ser, err := CacheSerializer(cfg.Codec, cfg.Compress)
+	if err != nil {
+		return nil, nil, err
+	}
+	timeout := cfg.GetTimeout()
+	store := NewRedisCacheStore(r.WithSerializer(ser), namespace, timeout)
+	expired := time.Duration(timeout) * time.Second
+	return caches.NewLRUCacher2(store, expired, cfg.GetMaxSize()), store, nil
+}
+
+// SetupXormCache 开启二级缓存，没有指定表时缓存所有表，否则只缓存beans中列出的表
+func SetupXormCache(eng *xorm.EngineGroup, r *RedisWrapper, cfg dialect.ConnConfig, beans ...any) error {
+	if cfg.Cache == nil {
+		return nil
+	}
+	cacher, store, err := NewXormCacher(r, cfg.Key, *cfg.Cache)
+	if err != nil {
+		return err
+	}
+	store.Register(beans...)
+	if len(cfg.Cache.Tables) == 0 {
+		eng.SetDefaultCacher(cacher)
+		return nil
+	}
+	engines := append([]*xorm.Engine{eng.Master()}, eng.Slaves()...)
+	for _, bean := range beans {
+		if !cfg.Cache.HasTable(eng.TableName(bean)) {
+			continue
+		}
+		for _, e := range engines {
+			if err = e.MapCacher(bean, cacher); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CacheSerializer 根据名称选择编码和压缩，编码默认为gob，压缩算法需要已经注册
+func CacheSerializer(codec, compress string) (*Serializer, error) {
+	var c Codec
+	var id byte
+	switch codec {
+	default:
+		return nil, fmt.Errorf("redisw: unknown codec %q", codec)
+	case "", "gob":
+		c = GobCodec
+	case "json":
+		c = JsonCodec
+	case "msgpack":
+		c = MsgpackCodec
+	}
+	switch compress {
+	default:
+		return nil, fmt.Errorf("redisw: unknown compress %q", compress)
+	case "", "none":
+		return NewSerializer(c, COMPRESS_NONE), nil
+	case "gzip":
+		id = COMPRESS_GZIP
+	case "zstd":
+		id = COMPRESS_ZSTD
+	}
+	if _, err := getCompressor(id); err != nil {
+		return nil, err
+	}
+	return NewSerializer(c, id), nil
+}
+
+// cacheTypeName 带包路径的类型名，区分不同包中的同名模型
+func cacheTypeName(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer {
+		return "*" + cacheTypeName(t.Elem())
+	}
+	if t.Name() != "" && t.PkgPath() != "" {
+		return t.PkgPath() + "." + t.Name()
+	}
+	return t.String()
+}
+
+// Register 登记值的类型，Put时也会自动登记，提前登记可以读取其他进程写入的缓存
+func (s *RedisCacheStore) Register(objs ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, obj := range objs {
+		t := reflect.TypeOf(obj)
+		s.types[cacheTypeName(t)] = t
+	}
+}
+
+func (s *RedisCacheStore) getType(name string) (reflect.Type, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	t, ok := s.types[name]
+	return t, ok
+}
+
+// GetKey 加上命名空间，过长或不可打印的键使用md5
+func (s *RedisCacheStore) GetKey(key string) string {
+	if len(key) > XORM_CACHE_KEY_MAX_LEN || !utf8.ValidString(key) ||
+		strings.IndexFunc(key, unicode.IsControl) >= 0 {
+		key = caches.Md5(key)
+	}
+	return XORM_CACHE_PREFIX + s.Namespace + ":" + key
+}
+
+// Put 保存类型名和编码后的值
+func (s *RedisCacheStore) Put(key string, value any) error {
+	if value == nil {
+		return s.Del(key)
+	}
+	t := reflect.TypeOf(value)
+	name := cacheTypeName(t)
+	if _, ok := s.getType(name); !ok {
+		s.Register(value)
+	}
+	data, err := s.GetSerializer().Encode(value)
+	if err != nil {
+		return err
+	}
+	data = append(append([]byte(name), 0), data...)
+	if s.Timeout > 0 {
+		_, err = s.SetVal(s.GetKey(key), data, s.Timeout)
+	} else {
+		_, err = s.Exec("SET", s.GetKey(key), data)
+	}
+	return err
+}
+
+// Get 读取并还原为保存时的类型，不存在时返回 caches.ErrNotExist
+func (s *RedisCacheStore) Get(key string) (any, error) {
+	data, err := s.GetBytes(s.GetKey(key))
+	if errors.Is(err, redis.ErrNil) {
+		return nil, caches.ErrNotExist
+	} else if err != nil {
+		return nil, err
+	}
+	pos := bytes.IndexByte(data, 0)
+	if pos < 0 {
+		return nil, caches.ErrNotExist
+	}
+	t, ok := s.getType(string(data[:pos]))
+	if !ok {
+		return nil, caches.ErrNotExist
+	}
+	if t.Kind() == reflect.Pointer {
+		ptr := reflect.New(t.Elem())
+		err = s.GetSerializer().Decode(data[pos+1:], ptr.Interface())
+		return ptr.Interface(), err
+	}
+	ptr := reflect.New(t)
+	err = s.GetSerializer().Decode(data[pos+1:], ptr.Interface())
+	return ptr.Elem().Interface(), err
+}
+
+// Del 删除缓存
+func (s *RedisCacheStore) Del(key string) error {
+	_, err := s.Delete(s.GetKey(key))
+	return err
+}
+
+// Clear 删除命名空间下的所有缓存
+func (s *RedisCacheStore) Clear() (int, error) {
+	return s.DeleteMatching(XORM_CACHE_PREFIX+s.Namespace+":*", 0)
+}
@@ -0,0 +1,55 @@
+package redisw_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/azhai/xgen/dialect"
+	"github.com/azhai/xgen/redisw"
+	"github.com/stretchr/testify/assert"
+	"xorm.io/xorm/caches"
+)
+
+type CacheUser struct {
+	Id   int64 `xorm:"pk autoincr"`
+	Name string
+}
+
+func TestFakeXormCache(t *testing.T) {
+	r := redisw.NewRedisFake()
+	store := redisw.NewRedisCacheStore(r, "local", 60)
+	assert.NoError(t, store.Put("cache_user-1", &CacheUser{Id: 1, Name: "a"}))
+	obj, err := store.Get("cache_user-1")
+	assert.NoError(t, err)
+	assert.Equal(t, &CacheUser{Id: 1, Name: "a"}, obj)
+	_, err = store.Get("cache_user-2")
+	assert.ErrorIs(t, err, caches.ErrNotExist)
+	_, err = redisw.NewRedisCacheStore(r, "local", 60).Get("cache_user-1") // 类型未登记
+	assert.ErrorIs(t, err, caches.ErrNotExist)
+	assert.NoError(t, store.Del("cache_user-1"))
+	_, err = store.Get("cache_user-1")
+	assert.ErrorIs(t, err, caches.ErrNotExist)
+
+	db := dialect.ConnConfig{Type: "sqlite", Key: "local",
+		Dialect: &dialect.Sqlite{Path: filepath.Join(t.TempDir(), "test.db")},
+		Cache:   &dialect.CacheConfig{Conn: "cache", Tables: []string{"cache_user"}, Codec: "json", Compress: "zstd"}}
+	eng := db.QuickConnectGroup(false, true)
+	assert.NoError(t, eng.Sync(&CacheUser{}))
+	_, err = eng.Insert(&CacheUser{Name: "b"})
+	assert.NoError(t, err)
+	assert.NoError(t, redisw.SetupXormCache(eng, r, db, &CacheUser{}))
+	user := &CacheUser{}
+	has, err := eng.ID(1).Get(user)
+	assert.True(t, has)
+	assert.NoError(t, err)
+	keys, err := r.Find("xorm:local:*")
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2) // 主键列表和整行数据
+
+	_, err = eng.DB().Exec("UPDATE cache_user SET name = 'c'") // 绕过xorm，缓存不会失效
+	assert.NoError(t, err)
+	user = &CacheUser{}
+	has, err = eng.ID(1).Get(user)
+	assert.True(t, has)
+	assert.Equal(t, "b", user.Name)
+}
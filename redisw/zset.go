@@ -0,0 +1,188 @@
+package redisw
+
+import (
+	"github.com/gomodule/redigo/redis"
+)
+
+// ZMember 有序集合成员和分数
+type ZMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// ZMembers 将 WITHSCORES 的应答转为成员列表
+func ZMembers(reply any, err error) ([]ZMember, error) {
+	var values []string
+	if values, err = redis.Strings(reply, err); err != nil {
+		return nil, err
+	}
+	result := make([]ZMember, 0, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		score, err := redis.Float64([]byte(values[i+1]), nil)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ZMember{Member: values[i], Score: score})
+	}
+	return result, nil
+}
+
+type RedisZSet struct {
+	name    string
+	timeout int
+	*RedisWrapper
+}
+
+func NewRedisZSet(r *RedisWrapper, name string, timeout int) *RedisZSet {
+	return &RedisZSet{RedisWrapper: r, name: name, timeout: timeout}
+}
+
+func (rz *RedisZSet) Exec(cmd string, args ...any) (any, error) {
+	args = append([]any{rz.name}, args...)
+	return rz.RedisWrapper.Exec(cmd, args...)
+}
+
+// GetSize 获取有序集合元素数量
+func (rz *RedisZSet) GetSize() int {
+	size, _ := redis.Int(rz.Exec("ZCARD"))
+	return size
+}
+
+// GetTimeout 获取剩余时间 -1=无限 -2=不存在 -3=出错
+func (rz *RedisZSet) GetTimeout(predict bool) int {
+	timeout := rz.RedisWrapper.GetTimeout(rz.name)
+	if timeout == -2 && predict { // 尚未设置，使用预定值
+		timeout = rz.timeout
+	}
+	return timeout
+}
+
+func (rz *RedisZSet) Expire(timeout int) (bool, error) {
+	return rz.RedisWrapper.Expire(rz.name, timeout)
+}
+
+func (rz *RedisZSet) DeleteAll() (bool, error) {
+	affects, err := rz.RedisWrapper.Delete(rz.name)
+	return affects > 0, err
+}
+
+// Add 添加或更新成员的分数，返回新增的数量
+func (rz *RedisZSet) Add(member any, score float64) (int, error) {
+	defer rz.Exec("EXPIRE", rz.timeout)
+	return redis.Int(rz.Exec("ZADD", score, member))
+}
+
+// AddMap 批量添加或更新成员的分数
+func (rz *RedisZSet) AddMap(data map[string]float64) (int, error) {
+	if len(data) == 0 {
+		return 0, KeysEmptyError
+	}
+	args := make([]any, 0, len(data)*2)
+	for member, score := range data {
+		args = append(args, score, member)
+	}
+	defer rz.Exec("EXPIRE", rz.timeout)
+	return redis.Int(rz.Exec("ZADD", args...))
+}
+
+// IncrScore 增加成员的分数，返回新的分数
+func (rz *RedisZSet) IncrScore(member any, offset float64) (float64, error) {
+	defer rz.Exec("EXPIRE", rz.timeout)
+	return redis.Float64(rz.Exec("ZINCRBY", offset, member))
+}
+
+func (rz *RedisZSet) OrigDelete(members ...any) (int, error) {
+	if len(members) == 0 {
+		return 0, KeysEmptyError
+	}
+	return redis.Int(rz.Exec("ZREM", members...))
+}
+
+func (rz *RedisZSet) Delete(members ...string) (int, error) {
+	return rz.OrigDelete(StrToList(members)...)
+}
+
+func (rz *RedisZSet) GetScore(member any) (float64, error) {
+	return redis.Float64(rz.Exec("ZSCORE", member))
+}
+
+func (rz *RedisZSet) OrigMulti(cmd string, members ...any) (any, error) {
+	if len(members) == 0 {
+		return nil, KeysEmptyError
+	}
+	return rz.Exec(cmd, members...)
+}
+
+func (rz *RedisZSet) GetMulti(members ...string) (any, error) {
+	return rz.OrigMulti("ZMSCORE", StrToList(members)...)
+}
+
+// LoadScores 多个成员的分数，不存在的成员分数为0
+func (rz *RedisZSet) LoadScores(members ...string) (map[string]float64, error) {
+	return ExecMapFloat(rz.GetMulti, members...)
+}
+
+// GetRank 成员的排名，从0开始，reverse表示从高分到低分
+func (rz *RedisZSet) GetRank(member any, reverse bool) (int, error) {
+	if reverse {
+		return redis.Int(rz.Exec("ZREVRANK", member))
+	}
+	return redis.Int(rz.Exec("ZRANK", member))
+}
+
+// CountByScore 分数在min和max之间的成员数量，可以使用 -inf +inf (1 等写法
+func (rz *RedisZSet) CountByScore(min, max string) (int, error) {
+	return redis.Int(rz.Exec("ZCOUNT", min, max))
+}
+
+// GetRange 按照排名获取一段成员，reverse表示从高分到低分
+func (rz *RedisZSet) GetRange(start, stop int, reverse bool) ([]ZMember, error) {
+	if reverse {
+		return ZMembers(rz.Exec("ZREVRANGE", start, stop, "WITHSCORES"))
+	}
+	return ZMembers(rz.Exec("ZRANGE", start, stop, "WITHSCORES"))
+}
+
+// GetRangeByScore 按照分数获取一段成员，count<=0表示不限数量
+func (rz *RedisZSet) GetRangeByScore(min, max string, offset, count int) ([]ZMember, error) {
+	args := []any{min, max, "WITHSCORES"}
+	if count > 0 {
+		args = append(args, "LIMIT", offset, count)
+	}
+	return ZMembers(rz.Exec("ZRANGEBYSCORE", args...))
+}
+
+// DeleteRangeByScore 删除分数在min和max之间的成员
+func (rz *RedisZSet) DeleteRangeByScore(min, max string) (int, error) {
+	return redis.Int(rz.Exec("ZREMRANGEBYSCORE", min, max))
+}
+
+// //////////////////////////////////////////////////////////
+// / redis sorted set 的排行榜                              ///
+// //////////////////////////////////////////////////////////
+
+// GetTop 排行榜前n名，从高分到低分
+func (rz *RedisZSet) GetTop(n int) ([]ZMember, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	return rz.GetRange(0, n-1, true)
+}
+
+// GetAround 排行榜上成员前后各n名，包括成员自己
+func (rz *RedisZSet) GetAround(member any, n int) ([]ZMember, error) {
+	rank, err := rz.GetRank(member, true)
+	if err != nil {
+		return nil, err
+	}
+	start := rank - n
+	if start < 0 {
+		start = 0
+	}
+	return rz.GetRange(start, rank+n, true)
+}
+
+// KeepTop 只保留排行榜前n名
+func (rz *RedisZSet) KeepTop(n int) (int, error) {
+	return redis.Int(rz.Exec("ZREMRANGEBYRANK", 0, -n-1))
+}
@@ -40,9 +40,10 @@ func SkelProject(outputDir, nameSpace, binName string, force bool) (err error) {
 		return
 	}
 	data := map[string]any{"NameSpace": nameSpace, "ProjName": filepath.Base(nameSpace)}
-	if err = web.GenFile(data, filepath.Join(outputDir, "handlers"), "record"); err != nil {
+	if err = web.GenFile(data, filepath.Join(outputDir, "handlers"), "record", "limit"); err != nil {
 		return
 	}
-	err = web.GenFile(data, filepath.Join(outputDir, "cmd"), "prepare")
+	// 和main在同一个包中
+	err = web.GenFile(data, filepath.Join(outputDir, "cmd", binName), "prepare")
 	return
 }
@@ -0,0 +1,26 @@
+package web
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/azhai/xgen/templater"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderSkeleton(t *testing.T) {
+	factory := templater.NewFactory("./", false)
+	data := map[string]any{"NameSpace": "example.com/demo", "ProjName": "demo"}
+	for _, name := range []string{"main", "prepare", "record", "limit"} {
+		content, err := factory.Render(name, data)
+		if !assert.NoError(t, err, name) {
+			continue
+		}
+		_, err = parser.ParseFile(token.NewFileSet(), name+".go", content, parser.AllErrors)
+		assert.NoError(t, err, name)
+		if name == "main" {
+			assert.Contains(t, string(content), "app.Use(handlers.RateLimit(nil))")
+		}
+	}
+}
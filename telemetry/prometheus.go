@@ -0,0 +1,248 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azhai/xgen/dialect"
+	"github.com/azhai/xgen/redisw"
+	"github.com/gomodule/redigo/redis"
+	"xorm.io/xorm"
+)
+
+const TELEMETRY_NAMESPACE = "xgen" // 指标名称的前缀
+
+// 默认的耗时分桶，单位：秒
+var DefaultBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+// PoolStats 连接池的状态
+type PoolStats struct {
+	Active       int // 正在使用和空闲的连接总数
+	Idle         int
+	WaitCount    int64
+	WaitDuration time.Duration
+}
+
+type seriesKey struct {
+	driver, conn, command string
+}
+
+type errorKey struct {
+	seriesKey
+	kind string
+}
+
+type histogram struct {
+	counts []uint64 // 每个桶各自的数量，输出时再累加
+	sum    float64
+	count  uint64
+}
+
+type poolSource struct {
+	driver, conn string
+	stats        func() PoolStats
+}
+
+// Collector 按照Prometheus文本格式输出命令耗时、错误数和连接池状态
+// 实现了 dialect.CommandHook 和 http.Handler
+type Collector struct {
+	Namespace string
+	Buckets   []float64
+	durations map[seriesKey]*histogram
+	errors    map[errorKey]uint64
+	pools     []poolSource
+	mu        sync.Mutex
+}
+
+var _ dialect.CommandHook = (*Collector)(nil)
+
+func NewCollector() *Collector {
+	return &Collector{
+		Namespace: TELEMETRY_NAMESPACE, Buckets: DefaultBuckets,
+		durations: make(map[seriesKey]*histogram),
+		errors:    make(map[errorKey]uint64),
+	}
+}
+
+// ErrorKind 错误的分类，服务端错误使用第一个单词，如 WRONGTYPE
+func ErrorKind(err error) string {
+	var serr *redisw.ServerError
+	var nerr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &serr):
+		return serr.Kind
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &nerr) && nerr.Timeout():
+		return "timeout"
+	case redisw.IsRetryable(err):
+		return "network"
+	}
+	return "error"
+}
+
+func (c *Collector) BeforeCommand(ctx context.Context, evt *dialect.CommandEvent) context.Context {
+	return ctx
+}
+
+func (c *Collector) AfterCommand(ctx context.Context, evt *dialect.CommandEvent) {
+	key := seriesKey{driver: evt.Driver, conn: evt.ConnKey, command: evt.Command}
+	seconds := evt.Duration.Seconds()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h, ok := c.durations[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(c.Buckets))}
+		c.durations[key] = h
+	}
+	if i := sort.SearchFloat64s(c.Buckets, seconds); i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.sum += seconds
+	h.count++
+	if evt.Err != nil && !errors.Is(evt.Err, redis.ErrNil) {
+		c.errors[errorKey{seriesKey: key, kind: ErrorKind(evt.Err)}]++
+	}
+}
+
+// AddPool 添加连接池，输出时才读取状态
+func (c *Collector) AddPool(driver, conn string, stats func() PoolStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pools = append(c.pools, poolSource{driver: driver, conn: conn, stats: stats})
+}
+
+// AddRedisPool 添加redis连接池，连接复用和集群没有状态可以读取
+func (c *Collector) AddRedisPool(r *redisw.RedisWrapper) bool {
+	pool, ok := r.RedisContainer.(*redis.Pool)
+	if ok {
+		c.AddPool("redis", r.ConnKey, func() PoolStats {
+			s := pool.Stats()
+			return PoolStats{Active: s.ActiveCount, Idle: s.IdleCount,
+				WaitCount: s.WaitCount, WaitDuration: s.WaitDuration}
+		})
+	}
+	return ok
+}
+
+// AddXormPool 添加数据库连接池
+func (c *Collector) AddXormPool(key string, engine *xorm.Engine) {
+	c.AddPool(engine.DriverName(), key, func() PoolStats {
+		s := engine.DB().Stats()
+		return PoolStats{Active: s.OpenConnections, Idle: s.Idle,
+			WaitCount: s.WaitCount, WaitDuration: s.WaitDuration}
+	})
+}
+
+func formatLabels(pairs ...string) string {
+	var buf strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(pairs[i+1])
+		buf.WriteString(pairs[i] + `="` + value + `"`)
+	}
+	return "{" + buf.String() + "}"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// WriteTo 输出所有指标，实现 io.WriterTo
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	c.mu.Lock()
+	c.writeCommands(&buf)
+	pools := append([]poolSource{}, c.pools...)
+	c.mu.Unlock()
+	c.writePools(&buf, pools)
+	return buf.WriteTo(w)
+}
+
+func (c *Collector) writeCommands(buf *bytes.Buffer) {
+	name := c.Namespace + "_command_duration_seconds"
+	fmt.Fprintf(buf, "# HELP %s Duration of redis commands and SQL statements.\n", name)
+	fmt.Fprintf(buf, "# TYPE %s histogram\n", name)
+	keys := make([]seriesKey, 0, len(c.durations))
+	for key := range c.durations {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		return a.driver+"\x00"+a.conn+"\x00"+a.command < b.driver+"\x00"+b.conn+"\x00"+b.command
+	})
+	for _, key := range keys {
+		h, acc := c.durations[key], uint64(0)
+		base := []string{"driver", key.driver, "conn", key.conn, "command", key.command}
+		for i, le := range c.Buckets {
+			acc += h.counts[i]
+			labels := formatLabels(append(base, "le", formatFloat(le))...)
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", name, labels, acc)
+		}
+		fmt.Fprintf(buf, "%s_bucket%s %d\n", name, formatLabels(append(base, "le", "+Inf")...), h.count)
+		fmt.Fprintf(buf, "%s_sum%s %s\n", name, formatLabels(base...), formatFloat(h.sum))
+		fmt.Fprintf(buf, "%s_count%s %d\n", name, formatLabels(base...), h.count)
+	}
+
+	name = c.Namespace + "_command_errors_total"
+	fmt.Fprintf(buf, "# HELP %s Failed redis commands and SQL statements.\n", name)
+	fmt.Fprintf(buf, "# TYPE %s counter\n", name)
+	lines := make([]string, 0, len(c.errors))
+	for key, count := range c.errors {
+		labels := formatLabels("driver", key.driver, "conn", key.conn,
+			"command", key.command, "kind", key.kind)
+		lines = append(lines, fmt.Sprintf("%s%s %d\n", name, labels, count))
+	}
+	sort.Strings(lines)
+	for _, line := range lines {
+		buf.WriteString(line)
+	}
+}
+
+func (c *Collector) writePools(buf *bytes.Buffer, pools []poolSource) {
+	if len(pools) == 0 {
+		return
+	}
+	stats := make([]PoolStats, len(pools))
+	for i, p := range pools {
+		stats[i] = p.stats()
+	}
+	write := func(suffix, kind, help string, value func(s PoolStats) string) {
+		name := c.Namespace + "_pool_" + suffix
+		fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for i, p := range pools {
+			labels := formatLabels("driver", p.driver, "conn", p.conn)
+			fmt.Fprintf(buf, "%s%s %s\n", name, labels, value(stats[i]))
+		}
+	}
+	write("active_connections", "gauge", "Open connections, in use or idle.",
+		func(s PoolStats) string { return strconv.Itoa(s.Active) })
+	write("idle_connections", "gauge", "Idle connections.",
+		func(s PoolStats) string { return strconv.Itoa(s.Idle) })
+	write("wait_total", "counter", "Times waited for a connection.",
+		func(s PoolStats) string { return strconv.FormatInt(s.WaitCount, 10) })
+	write("wait_seconds_total", "counter", "Time spent waiting for a connection.",
+		func(s PoolStats) string { return formatFloat(s.WaitDuration.Seconds()) })
+}
+
+// ServeHTTP 作为 /metrics 接口
+func (c *Collector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = c.WriteTo(w)
+}
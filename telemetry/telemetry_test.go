@@ -0,0 +1,69 @@
+package telemetry_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/azhai/xgen/dialect"
+	"github.com/azhai/xgen/redisw"
+	"github.com/azhai/xgen/telemetry"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSpan struct {
+	name  string
+	attrs map[string]any
+	err   error
+	ended bool
+}
+
+func (s *testSpan) SetAttribute(key string, value any) { s.attrs[key] = value }
+func (s *testSpan) RecordError(err error)              { s.err = err }
+func (s *testSpan) End()                               { s.ended = true }
+
+func TestCollector(t *testing.T) {
+	var spans []*testSpan
+	tracer := telemetry.TracerFunc(func(ctx context.Context, name string) (context.Context, telemetry.Span) {
+		span := &testSpan{name: name, attrs: make(map[string]any)}
+		spans = append(spans, span)
+		return ctx, span
+	})
+	c := telemetry.NewCollector()
+	dialect.RegisterCommandHooks(c, telemetry.NewSpanHook(tracer))
+	defer dialect.ResetCommandHooks()
+
+	r := redisw.NewRedisFake()
+	r.SetVal("test:a", "x", 60)
+	_, err := r.Exec("LPUSH", "test:a", "secret")
+	assert.ErrorIs(t, err, redisw.ErrWrongType)
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "redis LPUSH", spans[1].name)
+	assert.Equal(t, "LPUSH test:a ?", spans[1].attrs["db.query.text"])
+	assert.Equal(t, "WRONGTYPE", spans[1].attrs["error.type"])
+	assert.True(t, spans[1].ended)
+
+	cfg := dialect.ConnConfig{Type: "sqlite", Key: "local",
+		Dialect: &dialect.Sqlite{Path: filepath.Join(t.TempDir(), "test.db")}}
+	engine := cfg.QuickConnect(false, true)
+	_, err = engine.Exec("CREATE TABLE t (id INTEGER, name TEXT)")
+	assert.NoError(t, err)
+	_, err = engine.Exec("INSERT INTO t VALUES (?, ?)", 1, "secret")
+	assert.NoError(t, err)
+	c.AddXormPool(cfg.Key, engine)
+	assert.True(t, c.AddRedisPool(redisw.NewRedisPool(dialect.ConnConfig{Type: "redis", Key: "cache"}, 0)))
+
+	var buf strings.Builder
+	_, err = c.WriteTo(&buf)
+	assert.NoError(t, err)
+	out := buf.String()
+	assert.NotContains(t, out, "secret")
+	assert.Contains(t, out, `xgen_command_duration_seconds_count{driver="redis",conn="fake",command="SETEX"} 1`)
+	assert.Contains(t, out, `xgen_command_duration_seconds_count{driver="sqlite3",conn="local",command="INSERT"} 1`)
+	assert.Contains(t, out, `xgen_command_errors_total{driver="redis",conn="fake",command="LPUSH",kind="WRONGTYPE"} 1`)
+	assert.Contains(t, out, `xgen_pool_active_connections{driver="sqlite3",conn="local"} 1`)
+	assert.Contains(t, out, `xgen_pool_idle_connections{driver="redis",conn="cache"} 0`)
+	assert.Equal(t, "INSERT INTO t VALUES (?, ?) [2 args]", spans[len(spans)-1].attrs["db.query.text"])
+}
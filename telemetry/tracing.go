@@ -0,0 +1,69 @@
+package telemetry
+
+import (
+	"context"
+
+	"github.com/azhai/xgen/dialect"
+)
+
+// Span 追踪中的一段，对应 OpenTelemetry 的 trace.Span
+type Span interface {
+	SetAttribute(key string, value any)
+	RecordError(err error)
+	End()
+}
+
+// Tracer 开始一段追踪，对应 OpenTelemetry 的 trace.Tracer
+// 不直接依赖otel，用几行代码适配即可，例如
+//
+//	tracer := otel.Tracer("xgen")
+//	hook := telemetry.NewSpanHook(telemetry.TracerFunc(
+//		func(ctx context.Context, name string) (context.Context, telemetry.Span) {
+//			ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//			return ctx, otelSpan{span} // SetAttribute 中转为 attribute.KeyValue
+//		}))
+//	dialect.RegisterCommandHooks(hook)
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// TracerFunc 用函数实现 Tracer
+type TracerFunc func(ctx context.Context, name string) (context.Context, Span)
+
+func (f TracerFunc) Start(ctx context.Context, name string) (context.Context, Span) {
+	return f(ctx, name)
+}
+
+type spanKey struct{ hook *SpanHook }
+
+// SpanHook 每条命令一个span，属性按照OpenTelemetry数据库的语义约定
+type SpanHook struct {
+	Tracer Tracer
+}
+
+var _ dialect.CommandHook = (*SpanHook)(nil)
+
+func NewSpanHook(tracer Tracer) *SpanHook {
+	return &SpanHook{Tracer: tracer}
+}
+
+func (h *SpanHook) BeforeCommand(ctx context.Context, evt *dialect.CommandEvent) context.Context {
+	ctx, span := h.Tracer.Start(ctx, evt.Driver+" "+evt.Command)
+	span.SetAttribute("db.system", evt.Driver)
+	span.SetAttribute("db.operation.name", evt.Command)
+	span.SetAttribute("db.query.text", evt.Summary)
+	span.SetAttribute("xgen.conn", evt.ConnKey)
+	return context.WithValue(ctx, spanKey{h}, span)
+}
+
+func (h *SpanHook) AfterCommand(ctx context.Context, evt *dialect.CommandEvent) {
+	span, ok := ctx.Value(spanKey{h}).(Span)
+	if !ok {
+		return
+	}
+	if evt.Err != nil {
+		span.SetAttribute("error.type", ErrorKind(evt.Err))
+		span.RecordError(evt.Err)
+	}
+	span.End()
+}
@@ -113,34 +113,59 @@ func (m *{{$class}}) Save(changes map[string]any) error {
 import (
 	{{.AliasName}} "{{.NameSpace}}"
 	"github.com/azhai/xgen/dialect"
+	{{- if .Cache}}
+	"github.com/azhai/xgen/redisw"
+	{{- end}}
 	xq "github.com/azhai/xgen/xquery"
 	_ "{{.Import}}"
 	"xorm.io/xorm"
 )
 
-var engine *xorm.Engine
+var engine *xorm.EngineGroup
 
-// ConnectXorm 连接数据库
-func ConnectXorm(cfg dialect.ConnConfig) *xorm.Engine {
+// ConnectXorm 连接数据库，配置了只读副本时读写分离
+func ConnectXorm(cfg dialect.ConnConfig) *xorm.EngineGroup {
 	if d := cfg.LoadDialect(); d == nil || !d.IsXormDriver() {
 		return nil
 	}
-	return cfg.QuickConnect(true, true)
+	return cfg.QuickConnectGroup(true, true)
 }
 
-// Engine 获取当前数据库连接
+// Engine 获取主库连接
 func Engine() *xorm.Engine {
+	if eg := EngineGroup(); eg != nil {
+		return eg.Master()
+	}
+	return nil
+}
+
+// EngineGroup 获取主库和只读副本，读操作会分配到只读副本
+func EngineGroup() *xorm.EngineGroup {
 	if engine == nil {
 		cfg := models.GetConnConfig("{{.ConnName}}")
 		engine = ConnectXorm(cfg)
 		_ = SyncModels(engine)
+		{{- if .Cache}}
+		_ = SetupCache(engine, cfg)
+		{{- end}}
 	}
 	return engine
 }
-
+{{if .Cache}}
+// SetupCache 按配置使用Redis二级缓存，配置中没有cache时不做任何事
+func SetupCache(eng *xorm.EngineGroup, cfg dialect.ConnConfig) error {
+	if eng == nil || cfg.Cache == nil {
+		return nil
+	}
+	r := redisw.NewRedisPool(models.GetConnConfig(cfg.Cache.Conn), -1)
+	return redisw.SetupXormCache(eng, r, cfg, {{range .Classes}}
+		&{{.}}{},{{end}}
+	)
+}
+{{end}}
 // Query 生成查询
 func Query(opts ...xq.QueryOption) *xorm.Session {
-	qr := Engine().NewSession()
+	qr := EngineGroup().NewSession()
 	if len(opts) > 0 {
 		return xq.ApplyOptions(qr, opts)
 	}
@@ -152,17 +177,22 @@ func Quote(value string) string {
 	return Engine().Quote(value)
 }
 
-// InsertBatch 写入多行数据
+// InsertBatch 写入多行数据，每行是一个对象或者map
 func InsertBatch(tableName string, rows ...any) error {
 	if len(rows) == 0 {
 		return nil
 	}
 	modify := func(tx *xorm.Session) (int64, error) {
-		return tx.Table(tableName).Insert(rows)
+		return tx.Table(tableName).Insert(rows...)
 	}
 	return xq.ExecTx(Engine(), modify)
 }
 
+// InsertMaps 用一条语句写入多行map数据
+func InsertMaps(tableName string, rows []map[string]any) error {
+	return xq.InsertMaps(Engine(), tableName, rows)
+}
+
 // UpdateBatch 更新多行数据
 func UpdateBatch(tableName, pkey string, ids any, changes map[string]any) error {
 	if len(changes) == 0 || ids == nil {
@@ -175,7 +205,7 @@ func UpdateBatch(tableName, pkey string, ids any, changes map[string]any) error
 }
 
 // SyncModels 同步数据库表结构
-func SyncModels(eng *xorm.Engine) error {
+func SyncModels(eng *xorm.EngineGroup) error {
 	if eng == nil {
 		return fmt.Errorf("the connection is lost")
 	}
@@ -206,16 +236,12 @@ var (
 	sessReg  *redisw.SessionRegistry
 )
 
-// ConnectRedis 连接数据库
+// ConnectRedis 连接数据库，使用连接池，事务需要独占一个连接
 func ConnectRedis(cfg dialect.ConnConfig, db int) *redisw.RedisWrapper {
 	if cfg.Type != "redis" {
 		return nil
 	}
-	conn, err := redisw.NewRedisConnDb(cfg, db)
-	if err != nil {
-		panic(err)
-	}
-	return redisw.NewRedisConnMux(conn, nil)
+	return redisw.NewRedisPoolDb(cfg, db, -1)
 }
 
 // Pool 获得连接池
@@ -259,9 +285,14 @@ import (
 	{{.AliasName}} "{{.NameSpace}}"
 
 	"github.com/azhai/xgen/dialect"
+	"github.com/azhai/xgen/redisw"
 	"github.com/arriqaaq/flashdb"
 )
 
+const (
+	SESS_CREATE_TIMEOUT = 3600 * 5 // 会话生命期
+)
+
 var (
 	flashConn *flashdb.FlashDB
 )
@@ -290,5 +321,34 @@ func Singleton() *flashdb.FlashDB {
 	}
 	return flashConn
 }
+
+// Session 获得用户会话，不需要redis服务
+func Session(token string) *redisw.StoreSession {
+	store := redisw.NewKVSessionStore(flashKV{Singleton()})
+	return redisw.NewStoreSession(store, token, SESS_CREATE_TIMEOUT)
+}
+
+// DelSession 删除会话
+func DelSession(token string) bool {
+	affects, err := Session(token).DeleteAll()
+	return err == nil && affects
+}
+
+// flashKV 把flashdb包装为 redisw.KVStore
+type flashKV struct {
+	*flashdb.FlashDB
+}
+
+func (db flashKV) View(fn func(tx redisw.KVTx) error) error {
+	return db.FlashDB.View(func(tx *flashdb.Tx) error {
+		return fn(tx)
+	})
+}
+
+func (db flashKV) Update(fn func(tx redisw.KVTx) error) error {
+	return db.FlashDB.Update(func(tx *flashdb.Tx) error {
+		return fn(tx)
+	})
+}
 `
 )
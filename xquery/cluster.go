@@ -40,7 +40,7 @@ func ClusterInsertMulti(xi xorm.Interface, objs []IResetTable, reset, trunc bool
 
 var clusters = make(map[string]*ClusterMixin)
 
-func GetClusterMixinFor(kind, prefix string, engine *xorm.Engine) *ClusterMixin {
+func GetClusterMixinFor(kind, prefix string, engine IEngine) *ClusterMixin {
 	key := kind + ":" + prefix
 	if c, ok := clusters[key]; ok {
 		return c
@@ -178,13 +178,13 @@ func (m *ClusterMixin) Next() bool {
 
 // ClusterQuery 分布式查询
 type ClusterQuery struct {
-	engine  *xorm.Engine
+	engine  IEngine
 	filters []QueryOption
 	*ClusterMixin
 	*xorm.Session
 }
 
-func NewClusterQuery(engine *xorm.Engine, cluster *ClusterMixin) *ClusterQuery {
+func NewClusterQuery(engine IEngine, cluster *ClusterMixin) *ClusterQuery {
 	table := cluster.TableNamePrefix + cluster.GetSuffix()
 	return &ClusterQuery{
 		engine:       engine,
@@ -8,7 +8,7 @@ import (
 )
 
 // JoinQuery 联表查询
-func JoinQuery(engine *xorm.Engine, query *xorm.Session,
+func JoinQuery(engine IEngine, query *xorm.Session,
 		table, fkey string, foreign ForeignTable,
 ) (*xorm.Session, []string) {
 	frgTable, frgAlias := foreign.TableName(), foreign.AliasName()
@@ -49,7 +49,7 @@ func (f ForeignTable) TableName() string {
 
 // LeftJoinQuery Left Join 联表查询
 type LeftJoinQuery struct {
-	engine      *xorm.Engine
+	engine      IEngine
 	filters     []QueryOption
 	nativeTable string
 	Native      ITableName
@@ -59,7 +59,7 @@ type LeftJoinQuery struct {
 }
 
 // NewLeftJoinQuery native 为最左侧的主表，查询其所有字段
-func NewLeftJoinQuery(engine *xorm.Engine, native ITableName) *LeftJoinQuery {
+func NewLeftJoinQuery(engine IEngine, native ITableName) *LeftJoinQuery {
 	nativeTable := native.TableName()
 	return &LeftJoinQuery{
 		engine:      engine,
@@ -7,6 +7,7 @@ import (
 	xutils "github.com/azhai/xgen/utils"
 	"github.com/mitchellh/copystructure"
 	"xorm.io/xorm"
+	"xorm.io/xorm/core"
 )
 
 const (
@@ -24,7 +25,7 @@ type ModifyFunc func(tx *xorm.Session) (int64, error)
 type QueryOption func(qr *xorm.Session) *xorm.Session
 
 // Qprintf 对参数先进行转义Quote
-func Qprintf(engine *xorm.Engine, format string, args ...any) string {
+func Qprintf(engine IEngine, format string, args ...any) string {
 	if engine != nil {
 		for i, arg := range args {
 			args[i] = engine.Quote(arg.(string))
@@ -33,8 +34,19 @@ func Qprintf(engine *xorm.Engine, format string, args ...any) string {
 	return fmt.Sprintf(format, args...)
 }
 
+// ISessionMaker 可以创建会话，包括 *xorm.Engine 和 *xorm.EngineGroup
+type ISessionMaker interface {
+	NewSession() *xorm.Session
+}
+
+// IEngine 数据库连接，包括 *xorm.Engine 和 *xorm.EngineGroup
+type IEngine interface {
+	xorm.EngineInterface
+	DB() *core.DB
+}
+
 // ExecTx 执行事务
-func ExecTx(engine *xorm.Engine, modify ModifyFunc) error {
+func ExecTx(engine ISessionMaker, modify ModifyFunc) error {
 	tx := engine.NewSession() // 必须是新的session
 	defer tx.Close()
 	_ = tx.Begin()
@@ -45,6 +57,16 @@ func ExecTx(engine *xorm.Engine, modify ModifyFunc) error {
 	return tx.Commit()
 }
 
+// InsertMaps 在一个事务中写入多行数据，每行是字段名到值的映射
+func InsertMaps(engine ISessionMaker, table string, rows []map[string]any) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	return ExecTx(engine, func(tx *xorm.Session) (int64, error) {
+		return tx.Table(table).Insert(rows)
+	})
+}
+
 // ApplyOptions 使用查询条件
 func ApplyOptions(qr *xorm.Session, opts []QueryOption) *xorm.Session {
 	for _, opt := range opts {
@@ -177,7 +199,7 @@ func (r *RowIterator) IsEnough(n int) bool {
 }
 
 // prepare 准备查询范围条件
-func (r *RowIterator) prepare(eng *xorm.Engine, opts []QueryOption) []QueryOption {
+func (r *RowIterator) prepare(eng IEngine, opts []QueryOption) []QueryOption {
 	if model, ok := r.Bean.(ITableName); ok {
 		table := model.TableName()
 		opts = append(opts, WithTable(table))
@@ -263,7 +285,7 @@ func (r *RowIterator) IterCol(qr *xorm.Session, proc BeanFunc,
 }
 
 // FindIndex 迭代查询主键列表，后累计总行数
-func (r *RowIterator) FindIndex(eng *xorm.Engine, proc BeanFunc,
+func (r *RowIterator) FindIndex(eng IEngine, proc BeanFunc,
 	col string, opts ...QueryOption,
 ) (count int64, err error) {
 	opts = r.prepare(eng, opts)
@@ -273,7 +295,7 @@ func (r *RowIterator) FindIndex(eng *xorm.Engine, proc BeanFunc,
 }
 
 // FindAll 迭代查询每行，后累计总行数
-func (r *RowIterator) FindAll(eng *xorm.Engine, proc BeanFunc,
+func (r *RowIterator) FindAll(eng IEngine, proc BeanFunc,
 	opts ...QueryOption,
 ) (count int64, err error) {
 	opts = r.prepare(eng, opts)
@@ -283,7 +305,7 @@ func (r *RowIterator) FindAll(eng *xorm.Engine, proc BeanFunc,
 }
 
 // FindCount 迭代查询，先查询总行数
-func (r *RowIterator) FindCount(eng *xorm.Engine, proc BeanFunc,
+func (r *RowIterator) FindCount(eng IEngine, proc BeanFunc,
 	opts ...QueryOption,
 ) (count int64, err error) {
 	opts = r.prepare(eng, opts)
@@ -308,7 +330,7 @@ func NewRowChannel(iter *RowIterator) *RowChannel {
 }
 
 // Update 通用队列生产和消费
-func (r *RowChannel) Update(eng *xorm.Engine, proc BeanFunc,
+func (r *RowChannel) Update(eng IEngine, proc BeanFunc,
 	consume func(val any), col string, opts []QueryOption,
 ) error {
 	r.dataCh = make(chan any)
@@ -330,7 +352,7 @@ func (r *RowChannel) Update(eng *xorm.Engine, proc BeanFunc,
 }
 
 // UpdateIndex 消费主键列表
-func (r *RowChannel) UpdateIndex(eng *xorm.Engine, consume func(val any),
+func (r *RowChannel) UpdateIndex(eng IEngine, consume func(val any),
 	col string, opts ...QueryOption,
 ) error {
 	if col == "" {
@@ -344,7 +366,7 @@ func (r *RowChannel) UpdateIndex(eng *xorm.Engine, consume func(val any),
 }
 
 // UpdateAll 消费每行字典
-func (r *RowChannel) UpdateAll(eng *xorm.Engine, consume func(val any),
+func (r *RowChannel) UpdateAll(eng IEngine, consume func(val any),
 	opts ...QueryOption,
 ) error {
 	proc := func(bean any, col string) (int64, error) {
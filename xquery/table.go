@@ -8,7 +8,6 @@ import (
 	"time"
 
 	"github.com/azhai/xgen/utils"
-	"xorm.io/xorm"
 	"xorm.io/xorm/schemas"
 )
 
@@ -43,7 +42,7 @@ func GetNullString(data utils.NullString) (word string) {
 }
 
 // FindTables 找出符合前缀的表名
-func FindTables(engine *xorm.Engine, prefix string, fullName bool) []string {
+func FindTables(engine IEngine, prefix string, fullName bool) []string {
 	var result []string
 	db, ctx := engine.DB(), context.Background()
 	tables, err := engine.Dialect().GetTables(db, ctx)
@@ -65,7 +64,7 @@ func FindTables(engine *xorm.Engine, prefix string, fullName bool) []string {
 }
 
 // CreateTableLike 复制表结构，只用于MySQL
-func CreateTableLike(engine *xorm.Engine, curr, orig string) (bool, error) {
+func CreateTableLike(engine IEngine, curr, orig string) (bool, error) {
 	if engine.DriverName() != "mysql" {
 		err := fmt.Errorf("only support mysql/mariadb database")
 		return false, err
@@ -80,7 +79,7 @@ func CreateTableLike(engine *xorm.Engine, curr, orig string) (bool, error) {
 }
 
 // GetPrimaryKey 获取Model的主键
-func GetPrimaryKey(engine *xorm.Engine, m any) *schemas.Column {
+func GetPrimaryKey(engine IEngine, m any) *schemas.Column {
 	table, err := engine.TableInfo(m)
 	if err != nil {
 		return nil